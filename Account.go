@@ -0,0 +1,627 @@
+package binance_modules
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/sirupsen/logrus"
+)
+
+var accountLock = &sync.Mutex{}
+
+// account updates come in bursts while trading, margin is refreshed once per burst
+const marginRefreshDelay = 2 * time.Second
+
+type AccountHandler func(event *futures.WsUserDataEvent)
+
+type PositionStateHandler func(transition PositionTransition)
+
+// Account owns the single user data stream and keeps state for every symbol.
+// Strategies register for a symbol and receive a per-symbol Status view.
+type Account struct {
+	client        *futures.Client
+	exInfo        *ExchangeInfo
+	DualSide      bool
+	MultiAssets   bool
+	Balances      map[string]*futures.Balance
+	margin        *accountMargin
+	marginTimer   *time.Timer // scheduled margin refresh, nil when none
+	marginLock    sync.Mutex  // serializes margin refreshes
+	brackets      map[string][]futures.Bracket
+	statuses      map[string]*Status
+	handlers      map[string][]AccountHandler
+	stateHandlers map[string][]PositionStateHandler
+	balanceTimes  map[string]int64 // ms, local time the stream last updated the balance of the asset
+	positionTimes map[string]int64 // ms, the same for positions by positionKey
+	reconciler    *reconciler
+	strategies    map[string]string // client order id prefix -> strategy
+	PnL           *PnLLedger
+	Journal       *Journal
+	lock          sync.RWMutex
+	doneC         chan struct{}
+	stopC         chan struct{}
+	log           *Logger
+}
+
+var accountInstance *Account
+
+func GetAccount(ctx context.Context, client *futures.Client) (*Account, error) {
+	if accountInstance == nil {
+		accountLock.Lock()
+		defer accountLock.Unlock()
+		if accountInstance == nil {
+			account, err := newAccount(ctx, client)
+			if err != nil {
+				return accountInstance, err
+			}
+			accountInstance = account
+		}
+	}
+	return accountInstance, nil
+}
+
+func newAccount(ctx context.Context, client *futures.Client) (*Account, error) {
+	account := new(Account)
+	account.client = client
+	account.Balances = make(map[string]*futures.Balance)
+	account.margin = new(accountMargin)
+	account.brackets = make(map[string][]futures.Bracket)
+	account.statuses = make(map[string]*Status)
+	account.handlers = make(map[string][]AccountHandler)
+	account.stateHandlers = make(map[string][]PositionStateHandler)
+	account.strategies = make(map[string]string)
+	account.balanceTimes = make(map[string]int64)
+	account.positionTimes = make(map[string]int64)
+	account.PnL = NewPnLLedger()
+	account.reconciler = new(reconciler)
+
+	lg, err := GetLogger()
+	if err != nil {
+		return account, err
+	}
+	account.log = lg
+
+	account.Journal, err = GetJournal()
+	if err != nil {
+		account.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to open fills journal")
+		return account, err
+	}
+
+	account.exInfo, err = GetExchangeInfo(ctx, client)
+	if err != nil {
+		account.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get exchange info")
+		return account, err
+	}
+
+	account.PnL.Restore(account.Journal.Fills(), account.exInfo.MarginAsset)
+
+	err = account.load(ctx)
+	if err != nil {
+		return account, err
+	}
+
+	err = account.serve()
+	if err != nil {
+		return account, err
+	}
+	account.StartReconciler(defaultReconcileInterval)
+	return account, nil
+}
+
+type accountSnapshot struct {
+	time        int64
+	dualSide    bool
+	multiAssets bool
+	margin      *accountMargin
+	balances    []*futures.Balance
+	positions   []*futures.PositionRisk
+	orders      []*futures.Order
+	brackets    []*futures.LeverageBracket
+	premium     []*futures.PremiumIndex
+}
+
+// fetch gets state of the whole account with the same REST services as NewStatus
+func (a *Account) fetch(ctx context.Context) (*accountSnapshot, error) {
+	snapshot := &accountSnapshot{time: time.Now().UnixMilli()}
+
+	positionMode, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (*futures.PositionMode, error) {
+		return a.client.NewGetPositionModeService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get position mode")
+		return snapshot, err
+	}
+
+	multiAssets, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (bool, error) {
+		return getMultiAssetsMode(ctx, a.client)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get multi-assets mode")
+		return snapshot, err
+	}
+
+	margin, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (*accountMargin, error) {
+		return getAccountMargin(ctx, a.client)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get account margin")
+		return snapshot, err
+	}
+
+	balances, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) ([]*futures.Balance, error) {
+		return a.client.NewGetBalanceService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get balances")
+		return snapshot, err
+	}
+
+	positions, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) ([]*futures.PositionRisk, error) {
+		return a.client.NewGetPositionRiskService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get position risk")
+		return snapshot, err
+	}
+
+	orders, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) ([]*futures.Order, error) {
+		return a.client.NewListOpenOrdersService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get open orders")
+		return snapshot, err
+	}
+
+	brackets, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) ([]*futures.LeverageBracket, error) {
+		return a.client.NewGetLeverageBracketService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get leverage brackets")
+		return snapshot, err
+	}
+
+	premium, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) ([]*futures.PremiumIndex, error) {
+		return a.client.NewPremiumIndexService().Do(ctx)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get premium index")
+		return snapshot, err
+	}
+
+	snapshot.dualSide = positionMode.DualSidePosition
+	snapshot.multiAssets = multiAssets
+	snapshot.margin = margin
+	snapshot.balances = balances
+	snapshot.positions = positions
+	snapshot.orders = orders
+	snapshot.brackets = brackets
+	snapshot.premium = premium
+	return snapshot, nil
+}
+
+func (a *Account) load(ctx context.Context) error {
+	snapshot, err := a.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.DualSide = snapshot.dualSide
+	a.MultiAssets = snapshot.multiAssets
+	a.margin = snapshot.margin
+	a.Balances = make(map[string]*futures.Balance)
+	for _, balance := range snapshot.balances {
+		a.Balances[balance.Asset] = balance
+	}
+	for _, status := range a.statuses {
+		status.setMode(a.DualSide, a.MultiAssets)
+		status.setCollateral(snapshot.balances)
+		status.setMargin(a.margin)
+	}
+	for _, position := range snapshot.positions {
+		a.status(position.Symbol).setPosition(position)
+	}
+	symbolOrders := make(map[string][]*futures.Order)
+	for _, order := range snapshot.orders {
+		symbolOrders[order.Symbol] = append(symbolOrders[order.Symbol], order)
+		a.status(order.Symbol)
+	}
+	a.setRiskData(snapshot)
+	for symbol, status := range a.statuses {
+		status.Orders.Load(symbolOrders[symbol])
+		status.UpdateStates(time.Now().UnixMilli())
+	}
+
+	a.log.WithFields(logrus.Fields{
+		"balances": len(a.Balances),
+		"symbols":  len(a.statuses),
+		"orders":   len(snapshot.orders),
+	}).Info("Successfully loaded account")
+	return nil
+}
+
+// retryPolicy resyncs time of the account client on timestamp errors
+func (a *Account) retryPolicy() RetryPolicy {
+	return DefaultRetryPolicy.withTimeSync(a.client)
+}
+
+// status returns state of the symbol, creating it if needed. Caller must hold the lock.
+func (a *Account) status(symbol string) *Status {
+	status, ok := a.statuses[symbol]
+	if !ok {
+		status = newStatus(symbol, a.exInfo.MarginAsset(symbol), a.log)
+		status.DualSide = a.DualSide
+		status.MultiAssets = a.MultiAssets
+		status.setCollateral(a.balances())
+		status.setMargin(a.margin)
+		status.Brackets = a.brackets[symbol]
+		status.account = a
+		a.statuses[symbol] = status
+	}
+	return status
+}
+
+// setRiskData updates leverage brackets and funding rates of all symbols. Caller must hold the lock.
+func (a *Account) setRiskData(snapshot *accountSnapshot) {
+	for _, bracket := range snapshot.brackets {
+		a.brackets[bracket.Symbol] = bracket.Brackets
+		if status, ok := a.statuses[bracket.Symbol]; ok {
+			status.setBrackets(bracket.Brackets)
+		}
+	}
+	for _, premium := range snapshot.premium {
+		if status, ok := a.statuses[premium.Symbol]; ok {
+			status.setPremiumIndex(premium)
+		}
+	}
+}
+
+func (a *Account) balances() []*futures.Balance {
+	balances := make([]*futures.Balance, 0, len(a.Balances))
+	for _, balance := range a.Balances {
+		balances = append(balances, balance)
+	}
+	return balances
+}
+
+// scheduleMarginRefresh refreshes margin after the delay unless a refresh is already scheduled, it is called under the lock
+func (a *Account) scheduleMarginRefresh() {
+	if a.marginTimer != nil {
+		return
+	}
+	a.marginTimer = time.AfterFunc(marginRefreshDelay, func() {
+		a.lock.Lock()
+		a.marginTimer = nil
+		a.lock.Unlock()
+
+		// refresh is triggered by the stream, there is no caller context
+		a.refreshMargin(context.Background())
+	})
+}
+
+func (a *Account) refreshMargin(ctx context.Context) {
+	a.marginLock.Lock()
+	defer a.marginLock.Unlock()
+
+	margin, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (*accountMargin, error) {
+		return getAccountMargin(ctx, a.client)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to refresh account margin")
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.margin = margin
+	for _, status := range a.statuses {
+		status.setMargin(a.margin)
+	}
+}
+
+func (a *Account) refreshMultiAssets(ctx context.Context) {
+	multiAssets, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (bool, error) {
+		return getMultiAssetsMode(ctx, a.client)
+	})
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to refresh multi-assets mode")
+		return
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.MultiAssets = multiAssets
+	for _, status := range a.statuses {
+		status.setMode(a.DualSide, multiAssets)
+	}
+}
+
+func (a *Account) serve() error {
+	listenKey, err := GetListenKey(a.client)
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to get Listen Key")
+		return err
+	}
+	doneC, stopC, err := futures.WsUserDataServe(listenKey.Key(), a.updateHandler, a.errorHandler)
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to initialize User Data Stream")
+		return err
+	}
+	a.doneC = doneC
+	a.stopC = stopC
+	a.log.Info("Successfully initialized User Data Stream")
+	return nil
+}
+
+func (a *Account) Register(symbol string, handler AccountHandler) *Status {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.handlers[symbol] = append(a.handlers[symbol], handler)
+	return a.status(symbol)
+}
+
+// Assign attributes further fills and realized PnL of orders with the strategy name as
+// client order id prefix to the strategy, see OrderProvider.SetClientOrderIDPrefix
+func (a *Account) Assign(strategy string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.strategies[clientOrderIDPrefix(strategy)] = strategy
+}
+
+// strategy returns the strategy assigned to the prefix of the client order id
+func (a *Account) strategy(clientOrderID string) string {
+	prefix, _, found := strings.Cut(clientOrderID, "-")
+	if !found {
+		return ""
+	}
+	return a.strategies[prefix]
+}
+
+// OnPositionStateChange registers the handler and calls it with states of positions which are
+// already open as transitions from CLOSED, so positions open at startup are reported too
+func (a *Account) OnPositionStateChange(symbol string, handler PositionStateHandler) {
+	a.lock.Lock()
+	a.stateHandlers[symbol] = append(a.stateHandlers[symbol], handler)
+	transitions := a.openStates(symbol)
+	a.lock.Unlock()
+
+	for _, transition := range transitions {
+		handler(transition)
+	}
+}
+
+// PositionStates returns states of open positions of the symbol as transitions from CLOSED
+func (a *Account) PositionStates(symbol string) []PositionTransition {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.openStates(symbol)
+}
+
+// openStates is PositionStates without locking. Caller must hold the lock.
+func (a *Account) openStates(symbol string) []PositionTransition {
+	status, ok := a.statuses[symbol]
+	if !ok {
+		return nil
+	}
+	var (
+		transitions []PositionTransition
+		now         = time.Now().UnixMilli()
+	)
+	for _, side := range status.Sides() {
+		state := status.State(side)
+		if state != PositionStateClosed {
+			transitions = append(transitions, PositionTransition{Time: now, Symbol: symbol, Side: side, Old: PositionStateClosed, New: state})
+		}
+	}
+	return transitions
+}
+
+func (a *Account) Status(symbol string) *Status {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.status(symbol)
+}
+
+func (a *Account) Balance(asset string) *futures.Balance {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	return a.Balances[asset]
+}
+
+func (a *Account) updateHandler(event *futures.WsUserDataEvent) {
+	received := time.Now().UnixMilli()
+	a.log.WithFields(logrus.Fields{
+		"Event": event.Event,
+	}).Info("User Data Event recieved")
+
+	if event.Event == futures.UserDataEventTypeListenKeyExpired {
+		a.restart()
+		return
+	}
+
+	var (
+		handlers    []AccountHandler
+		transitions []PositionTransition
+	)
+
+	a.lock.Lock()
+	if event.Event == futures.UserDataEventTypeAccountUpdate {
+		for _, balance := range event.AccountUpdate.Balances {
+			a.Balances[balance.Asset] = BalanceAdapter(&balance)
+			a.balanceTimes[balance.Asset] = received
+		}
+		for _, position := range event.AccountUpdate.Positions {
+			a.status(position.Symbol)
+			a.positionTimes[positionKey(position.Symbol, position.Side)] = received
+		}
+		for _, status := range a.statuses {
+			status.AccountUpdate(&event.AccountUpdate)
+		}
+		for _, position := range event.AccountUpdate.Positions {
+			status := a.status(position.Symbol)
+			a.PnL.Mark(position.Symbol, status.UnrealizedPnL(), event.Time)
+			transitions = append(transitions, status.UpdateStates(event.Time)...)
+		}
+		a.scheduleMarginRefresh()
+		if len(event.AccountUpdate.Balances) > 0 {
+			for _, symbolHandlers := range a.handlers {
+				handlers = append(handlers, symbolHandlers...)
+			}
+		} else {
+			for _, position := range event.AccountUpdate.Positions {
+				handlers = append(handlers, a.handlers[position.Symbol]...)
+			}
+		}
+	}
+	if event.Event == futures.UserDataEventTypeAccountConfigUpdate {
+		if event.AccountConfigUpdate.Symbol == "" {
+			// multi-assets mode change has no symbol and the stream client does not decode the mode
+			go a.refreshMultiAssets(context.Background())
+			a.scheduleMarginRefresh()
+			for _, symbolHandlers := range a.handlers {
+				handlers = append(handlers, symbolHandlers...)
+			}
+		} else {
+			status := a.status(event.AccountConfigUpdate.Symbol)
+			status.ConfigUpdate(&event.AccountConfigUpdate)
+			handlers = append(handlers, a.handlers[event.AccountConfigUpdate.Symbol]...)
+		}
+	}
+	if event.Event == futures.UserDataEventTypeOrderTradeUpdate {
+		status := a.status(event.OrderTradeUpdate.Symbol)
+		status.OrderUpdate(&event.OrderTradeUpdate)
+		transitions = append(transitions, status.UpdateStates(event.Time)...)
+		a.PnL.Fill(&event.OrderTradeUpdate, a.strategy(event.OrderTradeUpdate.ClientOrderID), status.MarginAsset(), status.UnrealizedPnL())
+		if event.OrderTradeUpdate.ExecutionType == futures.OrderExecutionTypeTrade {
+			fill := FillAdapter(&event.OrderTradeUpdate)
+			fill.Strategy = a.strategy(fill.ClientOrderID)
+			err := a.Journal.Record(fill)
+			if err != nil {
+				a.log.WithFields(logrus.Fields{
+					"symbol":  fill.Symbol,
+					"tradeID": fill.TradeID,
+					"err":     err.Error(),
+				}).Error("Failed to record fill")
+			}
+		}
+		handlers = append(handlers, a.handlers[event.OrderTradeUpdate.Symbol]...)
+	}
+	a.lock.Unlock()
+
+	a.notifyStates(transitions)
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func positionKey(symbol string, side futures.PositionSideType) string {
+	return symbol + "/" + string(side)
+}
+
+// updateStates recalculates states of the status outside of the stream and notifies transitions
+func (a *Account) updateStates(status *Status) {
+	a.lock.Lock()
+	transitions := status.UpdateStates(time.Now().UnixMilli())
+	a.lock.Unlock()
+
+	a.notifyStates(transitions)
+}
+
+func (a *Account) notifyStates(transitions []PositionTransition) {
+	for _, transition := range transitions {
+		a.lock.RLock()
+		handlers := a.stateHandlers[transition.Symbol]
+		a.lock.RUnlock()
+
+		for _, handler := range handlers {
+			handler(transition)
+		}
+	}
+}
+
+func (a *Account) MarkPriceUpdate(event *futures.WsMarkPriceEvent) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	status := a.status(event.Symbol)
+	status.MarkPriceUpdate(event)
+	a.PnL.Mark(event.Symbol, status.UnrealizedPnL(), event.Time)
+}
+
+func (a *Account) errorHandler(err error) {
+	a.log.WithFields(logrus.Fields{
+		"err": err.Error(),
+	}).Error("User Data Stream error. Restarting...")
+
+	// error handler is called from the stream goroutine, doneC is closed after it returns
+	go a.reconnect(a.doneC)
+}
+
+func (a *Account) restart() {
+	a.log.Error("Listen Key expired. Restarting...")
+
+	listenKey, err := GetListenKey(a.client)
+	if err == nil {
+		err = listenKey.Restart()
+	}
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Fatal("Failed to renew Listen Key")
+	}
+	doneC := a.doneC
+	close(a.stopC)
+	go a.reconnect(doneC)
+}
+
+func (a *Account) reconnect(doneC chan struct{}) {
+	<-doneC
+	err := a.serve()
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Fatal("Failed to restart User Data Stream")
+	}
+	// events could be lost while the stream was down
+	a.reconcile(context.Background())
+}
@@ -0,0 +1,192 @@
+package binance_modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+// exchange answers with these codes when the setting already has the requested value
+const (
+	errCodeNoNeedToChangeMarginType   = -4046
+	errCodeNoNeedToChangePositionSide = -4059
+	errCodeSameMultiAssetsMode        = -4171
+)
+
+var ErrInvalidAccountSettings = errors.New("invalid account settings")
+
+// AccountSettings declares settings a strategy needs, zero values leave settings as they are
+type AccountSettings struct {
+	DualSide    *bool
+	MultiAssets *bool
+	MarginType  futures.MarginType
+	Leverage    int
+}
+
+func (s AccountSettings) validate() error {
+	if s.MultiAssets != nil && *s.MultiAssets && s.MarginType == futures.MarginTypeIsolated {
+		return fmt.Errorf("%w: isolated margin is not supported in multi-assets mode", ErrInvalidAccountSettings)
+	}
+	if s.MarginType != "" && s.MarginType != futures.MarginTypeIsolated && s.MarginType != futures.MarginTypeCrossed {
+		return fmt.Errorf("%w: margin type %q", ErrInvalidAccountSettings, s.MarginType)
+	}
+	if s.Leverage < 0 {
+		return fmt.Errorf("%w: leverage %d", ErrInvalidAccountSettings, s.Leverage)
+	}
+	return nil
+}
+
+// SetMarginType switches the symbol between ISOLATED and CROSSED margin
+func (op *OrderProvider) SetMarginType(ctx context.Context, symbol futures.Symbol, marginType futures.MarginType) error {
+	_, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op.client.NewChangeMarginTypeService().Symbol(symbol.Symbol).MarginType(marginType).Do(ctx)
+	})
+	if hasErrorCode(err, errCodeNoNeedToChangeMarginType) {
+		return nil
+	}
+	return err
+}
+
+// SetPositionMode switches the account between hedge (dual side) and one-way mode
+func (op *OrderProvider) SetPositionMode(ctx context.Context, dualSide bool) error {
+	_, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op.client.NewChangePositionModeService().DualSide(dualSide).Do(ctx)
+	})
+	if hasErrorCode(err, errCodeNoNeedToChangePositionSide) {
+		return nil
+	}
+	return err
+}
+
+func (op *OrderProvider) SetMultiAssetsMode(ctx context.Context, multiAssets bool) error {
+	params := url.Values{}
+	params.Set("multiAssetsMargin", strconv.FormatBool(multiAssets))
+
+	_, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) ([]byte, error) {
+		return signedRequest(ctx, &op.client, http.MethodPost, "/fapi/v1/multiAssetsMargin", params)
+	})
+	if hasErrorCode(err, errCodeSameMultiAssetsMode) {
+		return nil
+	}
+	return err
+}
+
+// AdjustIsolatedMargin adds positive amount to the isolated position margin and removes negative one
+func (op *OrderProvider) AdjustIsolatedMargin(ctx context.Context, symbol futures.Symbol, positionSide futures.PositionSideType, amount decimal.Decimal) error {
+	if amount.IsZero() {
+		return nil
+	}
+	actionType := 1
+	if amount.IsNegative() {
+		actionType = 2
+	}
+	service := op.client.NewUpdatePositionMarginService().
+		Symbol(symbol.Symbol).
+		Amount(amount.Abs().String()).
+		Type(actionType)
+	if positionSide != "" {
+		service.PositionSide(positionSide)
+	}
+
+	// the same adjustment applied twice changes margin twice, it is never retried
+	_, err := once(ctx, op.policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, service.Do(ctx)
+	})
+	return err
+}
+
+// EnsureAccountSettings reads current settings and changes only the ones which differ,
+// position mode goes first because other settings are validated against it
+func (op *OrderProvider) EnsureAccountSettings(ctx context.Context, symbol futures.Symbol, settings AccountSettings) error {
+	err := settings.validate()
+	if err != nil {
+		return err
+	}
+	lg, err := GetLogger()
+	if err != nil {
+		return err
+	}
+	changed := func(setting string, value interface{}) {
+		lg.WithFields(logrus.Fields{
+			"symbol":  symbol.Symbol,
+			"setting": setting,
+			"value":   value,
+		}).Info("Account setting changed")
+	}
+
+	if settings.DualSide != nil {
+		mode, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (*futures.PositionMode, error) {
+			return op.client.NewGetPositionModeService().Do(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		if mode.DualSidePosition != *settings.DualSide {
+			err = op.SetPositionMode(ctx, *settings.DualSide)
+			if err != nil {
+				return err
+			}
+			changed("dualSidePosition", *settings.DualSide)
+		}
+	}
+
+	if settings.MultiAssets != nil {
+		multiAssets, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (bool, error) {
+			return getMultiAssetsMode(ctx, &op.client)
+		})
+		if err != nil {
+			return err
+		}
+		if multiAssets != *settings.MultiAssets {
+			err = op.SetMultiAssetsMode(ctx, *settings.MultiAssets)
+			if err != nil {
+				return err
+			}
+			changed("multiAssetsMargin", *settings.MultiAssets)
+		}
+	}
+
+	if settings.MarginType == "" && settings.Leverage == 0 {
+		return nil
+	}
+	positions, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) ([]*futures.PositionRisk, error) {
+		return op.client.NewGetPositionRiskService().Symbol(symbol.Symbol).Do(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if len(positions) == 0 {
+		return fmt.Errorf("%w: no position info for %s", ErrInvalidAccountSettings, symbol.Symbol)
+	}
+	// margin type and leverage are the same for both sides in hedge mode
+	position := positions[0]
+
+	if settings.MarginType != "" && isIsolated(position) != (settings.MarginType == futures.MarginTypeIsolated) {
+		err = op.SetMarginType(ctx, symbol, settings.MarginType)
+		if err != nil {
+			return err
+		}
+		changed("marginType", settings.MarginType)
+	}
+	if settings.Leverage > 0 && position.Leverage != strconv.Itoa(settings.Leverage) {
+		_, err = op.SetLeverage(ctx, symbol, settings.Leverage)
+		if err != nil {
+			return err
+		}
+		changed("leverage", settings.Leverage)
+	}
+	return nil
+}
+
+func hasErrorCode(err error, code int64) bool {
+	var apiErr *common.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
@@ -42,6 +42,8 @@ func OrderAdapter(order *futures.WsOrderTradeUpdate) *futures.Order {
 		OrigType:         string(order.OriginalType),
 		PositionSide:     order.PositionSide,
 		ClosePosition:    order.IsClosingPosition,
+		UpdateTime:       order.TradeTime,
+		WorkingType:      order.WorkingType,
 	}
 
 	return &restOrder
@@ -96,3 +98,78 @@ func CreateOrderAdapter(order *futures.CreateOrderResponse) *futures.Order {
 	}
 	return &restOrder
 }
+
+// CreateOrderResponseAdapter restores placement result from the queried order
+func CreateOrderResponseAdapter(order *futures.Order) *futures.CreateOrderResponse {
+	response := futures.CreateOrderResponse{
+		Symbol:           order.Symbol,
+		OrderID:          order.OrderID,
+		ClientOrderID:    order.ClientOrderID,
+		Price:            order.Price,
+		OrigQuantity:     order.OrigQuantity,
+		ExecutedQuantity: order.ExecutedQuantity,
+		CumQuote:         order.CumQuote,
+		ReduceOnly:       order.ReduceOnly,
+		Status:           order.Status,
+		StopPrice:        order.StopPrice,
+		TimeInForce:      order.TimeInForce,
+		Type:             order.Type,
+		Side:             order.Side,
+		UpdateTime:       order.UpdateTime,
+		WorkingType:      order.WorkingType,
+		ActivatePrice:    order.ActivatePrice,
+		PriceRate:        order.PriceRate,
+		AvgPrice:         order.AvgPrice,
+		PositionSide:     order.PositionSide,
+		ClosePosition:    order.ClosePosition,
+		PriceProtect:     order.PriceProtect,
+	}
+	return &response
+}
+
+func CancelOrderAdapter(order *futures.CancelOrderResponse) *futures.Order {
+	restOrder := futures.Order{
+		Symbol:           order.Symbol,
+		OrderID:          order.OrderID,
+		ClientOrderID:    order.ClientOrderID,
+		Price:            order.Price,
+		ReduceOnly:       order.ReduceOnly,
+		OrigQuantity:     order.OrigQuantity,
+		ExecutedQuantity: order.ExecutedQuantity,
+		CumQuantity:      order.CumQuantity,
+		CumQuote:         order.CumQuote,
+		Status:           order.Status,
+		TimeInForce:      order.TimeInForce,
+		Type:             order.Type,
+		Side:             order.Side,
+		StopPrice:        order.StopPrice,
+		UpdateTime:       order.UpdateTime,
+		WorkingType:      order.WorkingType,
+		ActivatePrice:    order.ActivatePrice,
+		PriceRate:        order.PriceRate,
+		OrigType:         order.OrigType,
+		PositionSide:     order.PositionSide,
+		PriceProtect:     order.PriceProtect,
+	}
+	return &restOrder
+}
+
+func FillAdapter(update *futures.WsOrderTradeUpdate) *Fill {
+	fill := Fill{
+		Time:            update.TradeTime,
+		Symbol:          update.Symbol,
+		OrderID:         update.ID,
+		ClientOrderID:   update.ClientOrderID,
+		TradeID:         update.TradeID,
+		Side:            update.Side,
+		PositionSide:    update.PositionSide,
+		Type:            update.Type,
+		Price:           update.LastFilledPrice,
+		Quantity:        update.LastFilledQty,
+		Maker:           update.IsMaker,
+		Commission:      update.Commission,
+		CommissionAsset: update.CommissionAsset,
+		RealizedPnL:     update.RealizedPnL,
+	}
+	return &fill
+}
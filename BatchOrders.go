@@ -0,0 +1,270 @@
+package binance_modules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+const (
+	batchOrdersLimit = 5  // orders per batch placement
+	batchCancelLimit = 10 // orders per batch cancel
+)
+
+type BatchOrderResult struct {
+	Request *OrderRequest
+	Order   *futures.CreateOrderResponse
+	Err     error
+}
+
+type BatchCancelResult struct {
+	OrderID       int64
+	ClientOrderID string
+	Order         *futures.CancelOrderResponse
+	Err           error
+}
+
+// BatchOrders places orders in chunks of the exchange maximum, results are in order of requests
+func (op *OrderProvider) BatchOrders(ctx context.Context, requests []*OrderRequest) []BatchOrderResult {
+	results := make([]BatchOrderResult, len(requests))
+
+	var (
+		chunk        []int
+		unregistered int // accepted orders of symbols without tracked status, others are pending in it
+	)
+	for i, request := range requests {
+		results[i].Request = request
+		if request.ClientOrderID == "" {
+			request.ClientOrderID = op.NewClientOrderID()
+		}
+		err := op.reserve(request, unregistered)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if op.tracked(request.Symbol.Symbol) == nil {
+			unregistered++
+		}
+		chunk = append(chunk, i)
+		if len(chunk) == batchOrdersLimit {
+			op.batchOrders(ctx, results, chunk)
+			chunk = nil
+		}
+	}
+	if len(chunk) > 0 {
+		op.batchOrders(ctx, results, chunk)
+	}
+	return results
+}
+
+func (op *OrderProvider) batchOrders(ctx context.Context, results []BatchOrderResult, chunk []int) {
+	orders := make([]map[string]string, 0, len(chunk))
+	for _, i := range chunk {
+		orders = append(orders, op.orderParams(results[i].Request))
+	}
+	batch, err := json.Marshal(orders)
+	if err != nil {
+		setBatchOrderError(results, chunk, err)
+		for _, i := range chunk {
+			op.removePending(results[i].Request)
+		}
+		return
+	}
+
+	params := url.Values{}
+	params.Set("batchOrders", string(batch))
+	responses, err := batchRequest(ctx, op.policy, &op.client, http.MethodPost, params, len(chunk))
+	if err != nil {
+		setBatchOrderError(results, chunk, err)
+		// orders of the ambiguous batch stay pending until stream or reconciliation shows them
+		if !IsRetryable(err) {
+			for _, i := range chunk {
+				op.removePending(results[i].Request)
+			}
+		}
+		return
+	}
+
+	for j, i := range chunk {
+		if apiErr := responseError(responses[j]); apiErr != nil {
+			results[i].Err = apiErr
+			op.removePending(results[i].Request)
+			continue
+		}
+		order := new(futures.CreateOrderResponse)
+		err = json.Unmarshal(responses[j], order)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Order = order
+		if status := op.tracked(order.Symbol); status != nil {
+			status.CreateOrderUpdate(order)
+		}
+	}
+}
+
+func (op *OrderProvider) removePending(request *OrderRequest) {
+	if status := op.tracked(request.Symbol.Symbol); status != nil {
+		status.Orders.RemovePending(request.ClientOrderID)
+	}
+}
+
+func setBatchOrderError(results []BatchOrderResult, chunk []int, err error) {
+	for _, i := range chunk {
+		results[i].Err = err
+	}
+}
+
+// orderParams builds batch entry of a validated request
+func (op *OrderProvider) orderParams(r *OrderRequest) map[string]string {
+	params := map[string]string{
+		"symbol": r.Symbol.Symbol,
+		"side":   string(r.Side),
+		"type":   string(r.Type),
+	}
+	if r.PositionSide != "" {
+		params["positionSide"] = string(r.PositionSide)
+	}
+	if r.TimeInForce != "" {
+		params["timeInForce"] = string(r.TimeInForce)
+	}
+	if !r.Quantity.IsZero() {
+		params["quantity"] = op.quantityToString(r.Quantity, r.Symbol.LotSizeFilter())
+	}
+	if !r.Price.IsZero() {
+		params["price"] = op.priceToString(r.Price, r.Symbol.PriceFilter())
+	}
+	if !r.StopPrice.IsZero() {
+		params["stopPrice"] = op.priceToString(r.StopPrice, r.Symbol.PriceFilter())
+	}
+	if !r.ActivationPrice.IsZero() {
+		params["activationPrice"] = op.priceToString(r.ActivationPrice, r.Symbol.PriceFilter())
+	}
+	if r.CallbackRate != 0 {
+		params["callbackRate"] = strconv.FormatFloat(r.CallbackRate, 'f', 1, 64)
+	}
+	if r.IsReduceOnly {
+		params["reduceOnly"] = "true"
+	}
+	if r.IsClosePosition {
+		params["closePosition"] = "true"
+	}
+	if r.WorkingType != "" {
+		params["workingType"] = string(r.WorkingType)
+	}
+	if r.IsPriceProtect {
+		params["priceProtect"] = "TRUE"
+	}
+	if r.ClientOrderID != "" {
+		params["newClientOrderId"] = r.ClientOrderID
+	}
+	return params
+}
+
+func (op *OrderProvider) BatchCancel(ctx context.Context, symbol futures.Symbol, orderIDs []int64) []BatchCancelResult {
+	results := make([]BatchCancelResult, len(orderIDs))
+	for i, orderID := range orderIDs {
+		results[i].OrderID = orderID
+	}
+	op.batchCancel(ctx, symbol, results, "orderIdList", func(result *BatchCancelResult) interface{} {
+		return result.OrderID
+	})
+	return results
+}
+
+func (op *OrderProvider) BatchCancelClient(ctx context.Context, symbol futures.Symbol, clientOrderIDs []string) []BatchCancelResult {
+	results := make([]BatchCancelResult, len(clientOrderIDs))
+	for i, clientOrderID := range clientOrderIDs {
+		results[i].ClientOrderID = clientOrderID
+	}
+	op.batchCancel(ctx, symbol, results, "origClientOrderIdList", func(result *BatchCancelResult) interface{} {
+		return result.ClientOrderID
+	})
+	return results
+}
+
+func (op *OrderProvider) batchCancel(ctx context.Context, symbol futures.Symbol, results []BatchCancelResult, key string, id func(result *BatchCancelResult) interface{}) {
+	for start := 0; start < len(results); start += batchCancelLimit {
+		end := start + batchCancelLimit
+		if end > len(results) {
+			end = len(results)
+		}
+		chunk := results[start:end]
+
+		ids := make([]interface{}, 0, len(chunk))
+		for i := range chunk {
+			ids = append(ids, id(&chunk[i]))
+		}
+		list, err := json.Marshal(ids)
+		if err != nil {
+			setBatchCancelError(chunk, err)
+			continue
+		}
+
+		params := url.Values{}
+		params.Set("symbol", symbol.Symbol)
+		params.Set(key, string(list))
+		responses, err := batchRequest(ctx, op.policy, &op.client, http.MethodDelete, params, len(chunk))
+		if err != nil {
+			setBatchCancelError(chunk, err)
+			continue
+		}
+
+		for i := range chunk {
+			if apiErr := responseError(responses[i]); apiErr != nil {
+				chunk[i].Err = apiErr
+				continue
+			}
+			order := new(futures.CancelOrderResponse)
+			err = json.Unmarshal(responses[i], order)
+			if err != nil {
+				chunk[i].Err = err
+				continue
+			}
+			chunk[i].Order = order
+			if status := op.tracked(order.Symbol); status != nil {
+				status.CancelOrderUpdate(order)
+			}
+		}
+	}
+}
+
+func setBatchCancelError(results []BatchCancelResult, err error) {
+	for i := range results {
+		results[i].Err = err
+	}
+}
+
+// batchRequest returns raw entries of the batch response, each one is an order or an error
+func batchRequest(ctx context.Context, policy RetryPolicy, client *futures.Client, method string, params url.Values, size int) ([]json.RawMessage, error) {
+	data, err := once(ctx, policy, func(ctx context.Context) ([]byte, error) {
+		return signedRequest(ctx, client, method, "/fapi/v1/batchOrders", params)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var responses []json.RawMessage
+	err = json.Unmarshal(data, &responses)
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) != size {
+		return nil, fmt.Errorf("unexpected batch response size %d, expected %d", len(responses), size)
+	}
+	return responses, nil
+}
+
+func responseError(data json.RawMessage) *common.APIError {
+	apiErr := new(common.APIError)
+	if json.Unmarshal(data, apiErr) != nil || apiErr.Code == 0 {
+		return nil
+	}
+	return apiErr
+}
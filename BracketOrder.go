@@ -0,0 +1,333 @@
+package binance_modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrNotTracked = errors.New("order provider does not track status of the symbol")
+
+// placing of failed exits is retried with backoff between these delays
+const (
+	exitRetryDelay    = time.Second
+	exitRetryMaxDelay = 30 * time.Second
+)
+
+type BracketOrderState string
+
+const (
+	BracketOrderStatePending  BracketOrderState = "PENDING"  // entry is not filled
+	BracketOrderStateActive   BracketOrderState = "ACTIVE"   // exits protect filled quantity
+	BracketOrderStateExposed  BracketOrderState = "EXPOSED"  // placing exits for filled quantity failed, see Err
+	BracketOrderStateClosed   BracketOrderState = "CLOSED"   // one of exits is filled
+	BracketOrderStateCanceled BracketOrderState = "CANCELED" // entry is canceled without fills
+)
+
+// BracketOrderRequest describes entry with stop-loss and take-profit, zero price disables the exit
+type BracketOrderRequest struct {
+	Entry       *OrderRequest // MARKET or LIMIT
+	StopLoss    decimal.Decimal
+	TakeProfit  decimal.Decimal
+	WorkingType futures.WorkingType
+}
+
+// BracketOrder emulates OCO on client side: exits are sized to filled entry quantity
+// and the sibling is canceled when one of them is filled
+type BracketOrder struct {
+	lock         sync.Mutex
+	provider     *OrderProvider
+	status       *Status
+	request      *BracketOrderRequest
+	state        BracketOrderState
+	protected    decimal.Decimal // quantity of placed exits
+	err          error           // last failure to place exits, nil once they are placed
+	idsLock      sync.RWMutex    // ids are read by the stream handler
+	stopLossID   string
+	takeProfitID string
+	unsubscribe  func()
+	notifyC      chan struct{}
+	stopOnce     sync.Once
+	stopC        chan struct{}
+	doneC        chan struct{}
+	log          *Logger
+}
+
+// PlaceBracketOrder submits the entry and manages exits by user data stream of the tracked status.
+// When the entry outcome is unknown the bracket is returned with the error and manages the entry if it exists.
+func (op *OrderProvider) PlaceBracketOrder(ctx context.Context, request *BracketOrderRequest) (*BracketOrder, error) {
+	entry := request.Entry
+	if entry.Type != futures.OrderTypeMarket && entry.Type != futures.OrderTypeLimit {
+		return nil, fmt.Errorf("%w: bracket entry must be MARKET or LIMIT, got %s", ErrInvalidOrderRequest, entry.Type)
+	}
+	if request.StopLoss.IsZero() && request.TakeProfit.IsZero() {
+		return nil, fmt.Errorf("%w: bracket requires stop-loss or take-profit", ErrInvalidOrderRequest)
+	}
+	if !request.StopLoss.IsZero() && !request.TakeProfit.IsZero() {
+		if (entry.Side == futures.SideTypeBuy) != request.StopLoss.LessThan(request.TakeProfit) {
+			return nil, fmt.Errorf("%w: stop-loss %s and take-profit %s are on wrong sides", ErrInvalidOrderRequest, request.StopLoss, request.TakeProfit)
+		}
+	}
+	status := op.tracked(entry.Symbol.Symbol)
+	if status == nil {
+		return nil, ErrNotTracked
+	}
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+	if entry.ClientOrderID == "" {
+		entry.ClientOrderID = op.NewClientOrderID()
+	}
+
+	bracket := &BracketOrder{
+		provider:  op,
+		status:    status,
+		request:   request,
+		state:     BracketOrderStatePending,
+		protected: decimal.Zero,
+		notifyC:   make(chan struct{}, 1),
+		stopC:     make(chan struct{}),
+		doneC:     make(chan struct{}),
+		log:       lg,
+	}
+	// subscribe before submit to not miss fills of market entry
+	bracket.unsubscribe = status.OnOrderUpdate(bracket.orderHandler)
+
+	// the entry is kept while its outcome is unknown or its state is already received
+	_, err = op.Submit(ctx, entry)
+	if err != nil && !status.Orders.IsPending(entry.ClientOrderID) && status.Orders.GetByClientID(entry.ClientOrderID) == nil {
+		bracket.unsubscribe()
+		return nil, err
+	}
+
+	go bracket.run()
+	bracket.notify()
+	return bracket, err
+}
+
+func (b *BracketOrder) State() BracketOrderState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+// Err returns the last failure to place exits while the bracket is EXPOSED, placing is retried until
+// it succeeds or the bracket is canceled, the position may need to be closed by the caller
+func (b *BracketOrder) Err() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.err
+}
+
+// Done is closed when the bracket is closed, canceled or stopped
+func (b *BracketOrder) Done() <-chan struct{} {
+	return b.doneC
+}
+
+// Cancel stops managing the bracket and cancels entry and exits which are still open
+func (b *BracketOrder) Cancel(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stopC) })
+	<-b.doneC
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var firstErr error
+	for _, clientOrderID := range []string{b.request.Entry.ClientOrderID, b.stopLossID, b.takeProfitID} {
+		err := b.cancel(ctx, clientOrderID)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if b.state == BracketOrderStatePending || b.state == BracketOrderStateActive || b.state == BracketOrderStateExposed {
+		b.state = BracketOrderStateCanceled
+	}
+	return firstErr
+}
+
+func (b *BracketOrder) orderHandler(order *futures.Order) {
+	b.idsLock.RLock()
+	defer b.idsLock.RUnlock()
+
+	switch order.ClientOrderID {
+	case b.request.Entry.ClientOrderID, b.stopLossID, b.takeProfitID:
+		b.notify()
+	}
+}
+
+func (b *BracketOrder) notify() {
+	select {
+	case b.notifyC <- struct{}{}:
+	default:
+	}
+}
+
+func (b *BracketOrder) run() {
+	defer close(b.doneC)
+	defer b.unsubscribe()
+
+	var (
+		retryC <-chan time.Time
+		delay  = exitRetryDelay
+	)
+	for {
+		select {
+		case <-b.stopC:
+			return
+		case <-b.notifyC:
+		case <-retryC:
+		}
+
+		finished, failed := b.sync()
+		if finished {
+			return
+		}
+		retryC = nil
+		if failed {
+			// a filled market entry has no more updates, exits are retried by the timer
+			retryC = time.After(delay)
+			delay *= 2
+			if delay > exitRetryMaxDelay {
+				delay = exitRetryMaxDelay
+			}
+		} else {
+			delay = exitRetryDelay
+		}
+	}
+}
+
+// sync acts on the current state of orders in status, returns whether the bracket is finished
+// and whether placing of exits failed
+func (b *BracketOrder) sync() (bool, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	ctx := context.Background()
+	entry := b.status.Orders.GetByClientID(b.request.Entry.ClientOrderID)
+
+	for _, exit := range []struct{ id, sibling string }{{b.stopLossID, b.takeProfitID}, {b.takeProfitID, b.stopLossID}} {
+		order := b.status.Orders.GetByClientID(exit.id)
+		if exit.id == "" || order == nil || order.Status != futures.OrderStatusTypeFilled {
+			continue
+		}
+		b.logError(b.cancel(ctx, exit.sibling), exit.sibling)
+		if entry != nil && !IsTerminalOrderStatus(entry.Status) {
+			b.logError(b.cancel(ctx, entry.ClientOrderID), entry.ClientOrderID)
+		}
+		b.state = BracketOrderStateClosed
+		return true, false
+	}
+
+	if entry == nil {
+		return false, false
+	}
+	filled := parseDecimal(entry.ExecutedQuantity)
+	if filled.GreaterThan(b.protected) {
+		err := b.replaceExits(ctx, filled)
+		if err != nil {
+			b.log.WithFields(logrus.Fields{
+				"symbol":        entry.Symbol,
+				"clientOrderID": entry.ClientOrderID,
+				"filled":        filled.String(),
+				"err":           err.Error(),
+			}).Error("Failed to place bracket exits")
+			b.state = BracketOrderStateExposed
+			b.err = err
+			return false, true
+		}
+		b.state = BracketOrderStateActive
+		b.err = nil
+	}
+	if IsTerminalOrderStatus(entry.Status) && filled.IsZero() {
+		b.state = BracketOrderStateCanceled
+		return true, false
+	}
+	return false, false
+}
+
+// replaceExits places exits for the new filled quantity before canceling the old ones
+func (b *BracketOrder) replaceExits(ctx context.Context, quantity decimal.Decimal) error {
+	stopLossID, err := b.placeExit(ctx, futures.OrderTypeStopMarket, b.request.StopLoss, quantity)
+	if err != nil {
+		return err
+	}
+	takeProfitID, err := b.placeExit(ctx, futures.OrderTypeTakeProfitMarket, b.request.TakeProfit, quantity)
+	if err != nil {
+		b.logError(b.cancel(ctx, stopLossID), stopLossID)
+		return err
+	}
+
+	b.logError(b.cancel(ctx, b.stopLossID), b.stopLossID)
+	b.logError(b.cancel(ctx, b.takeProfitID), b.takeProfitID)
+	b.idsLock.Lock()
+	b.stopLossID, b.takeProfitID = stopLossID, takeProfitID
+	b.idsLock.Unlock()
+	b.protected = quantity
+	// the handler drops updates of the new exits which came before the swap
+	b.notify()
+	return nil
+}
+
+func (b *BracketOrder) placeExit(ctx context.Context, orderType futures.OrderType, stopPrice, quantity decimal.Decimal) (string, error) {
+	if stopPrice.IsZero() {
+		return "", nil
+	}
+	entry := b.request.Entry
+	side := futures.SideTypeSell
+	if entry.Side == futures.SideTypeSell {
+		side = futures.SideTypeBuy
+	}
+
+	request := NewOrderRequest(entry.Symbol, side, orderType).
+		WithPositionSide(entry.PositionSide).
+		WithQuantity(quantity).
+		WithStopPrice(stopPrice)
+	if b.request.WorkingType != "" {
+		request.WithWorkingType(b.request.WorkingType)
+	}
+	// in hedge mode orders of the opposite side close the position without reduceOnly
+	if entry.PositionSide == "" || entry.PositionSide == futures.PositionSideTypeBoth {
+		request.ReduceOnly()
+	}
+
+	_, err := b.provider.Submit(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	return request.ClientOrderID, nil
+}
+
+func (b *BracketOrder) cancel(ctx context.Context, clientOrderID string) error {
+	if clientOrderID == "" {
+		return nil
+	}
+	order := b.status.Orders.GetByClientID(clientOrderID)
+	if order != nil && IsTerminalOrderStatus(order.Status) {
+		return nil
+	}
+	_, err := b.provider.CancelClientOrder(ctx, b.request.Entry.Symbol, clientOrderID)
+	if isOrderNotOpen(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *BracketOrder) logError(err error, clientOrderID string) {
+	if err == nil {
+		return
+	}
+	b.log.WithFields(logrus.Fields{
+		"symbol":        b.request.Entry.Symbol.Symbol,
+		"clientOrderID": clientOrderID,
+		"err":           err.Error(),
+	}).Error("Failed to cancel bracket order")
+}
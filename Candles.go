@@ -2,17 +2,45 @@ package binance_modules
 
 import (
 	"context"
+	"sync"
 
 	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
 )
 
+type Candle struct {
+	OpenTime  int64
+	CloseTime int64
+	Open      decimal.Decimal
+	High      decimal.Decimal
+	Low       decimal.Decimal
+	Close     decimal.Decimal
+	Volume    decimal.Decimal
+}
+
+func newCandle(kline *futures.Kline) Candle {
+	return Candle{
+		OpenTime:  kline.OpenTime,
+		CloseTime: kline.CloseTime,
+		Open:      parseDecimal(kline.Open),
+		High:      parseDecimal(kline.High),
+		Low:       parseDecimal(kline.Low),
+		Close:     parseDecimal(kline.Close),
+		Volume:    parseDecimal(kline.Volume),
+	}
+}
+
+// Candles is updated by kline stream and read by strategies concurrently
 type Candles struct {
+	lock    sync.RWMutex
 	candles []*futures.Kline
 }
 
-func NewCandles(client *futures.Client, symbol, timeframe string, limit int) (*Candles, error) {
+func NewCandles(ctx context.Context, client *futures.Client, symbol, timeframe string, limit int) (*Candles, error) {
 	candles := new(Candles)
-	klines, err := client.NewKlinesService().Symbol(symbol).Interval(timeframe).Limit(limit).Do(context.Background())
+	klines, err := retry(ctx, DefaultRetryPolicy, func(ctx context.Context) ([]*futures.Kline, error) {
+		return client.NewKlinesService().Symbol(symbol).Interval(timeframe).Limit(limit).Do(ctx)
+	})
 
 	if err != nil {
 		return candles, err
@@ -22,6 +50,9 @@ func NewCandles(client *futures.Client, symbol, timeframe string, limit int) (*C
 }
 
 func (c *Candles) Update(update *futures.WsKline) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
 	if len(c.candles) > 0 {
 		if c.candles[len(c.candles)-1].OpenTime == update.StartTime {
 			c.candles[len(c.candles)-1] = KlineAdapter(update)
@@ -33,3 +64,87 @@ func (c *Candles) Update(update *futures.WsKline) {
 		c.candles = append(c.candles, KlineAdapter(update))
 	}
 }
+
+func (c *Candles) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.candles)
+}
+
+// Candles returns candles from the oldest to the current one
+func (c *Candles) Candles() []Candle {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	result := make([]Candle, 0, len(c.candles))
+	for _, kline := range c.candles {
+		result = append(result, newCandle(kline))
+	}
+	return result
+}
+
+func (c *Candles) Last() (Candle, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.candles) == 0 {
+		return Candle{}, false
+	}
+	return newCandle(c.candles[len(c.candles)-1]), true
+}
+
+// Closes returns close prices as floats for indicator libraries
+func (c *Candles) Closes() []float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	result := make([]float64, 0, len(c.candles))
+	for _, kline := range c.candles {
+		result = append(result, parseDecimal(kline.Close).InexactFloat64())
+	}
+	return result
+}
+
+// ATR returns simple average of true ranges of the last period candles, zero if there is not enough candles
+func (c *Candles) ATR(period int) decimal.Decimal {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if period <= 0 || len(c.candles) < period+1 {
+		return decimal.Zero
+	}
+
+	sum := decimal.Zero
+	for i := len(c.candles) - period; i < len(c.candles); i++ {
+		high := parseDecimal(c.candles[i].High)
+		low := parseDecimal(c.candles[i].Low)
+		prevClose := parseDecimal(c.candles[i-1].Close)
+
+		trueRange := high.Sub(low)
+		trueRange = decimal.Max(trueRange, high.Sub(prevClose).Abs())
+		trueRange = decimal.Max(trueRange, low.Sub(prevClose).Abs())
+		sum = sum.Add(trueRange)
+	}
+	return sum.Div(decimal.NewFromInt(int64(period)))
+}
+
+// VolumeSince returns volume traded from t (ms), volume of the candle containing t is prorated by time
+func (c *Candles) VolumeSince(t int64) decimal.Decimal {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	volume := decimal.Zero
+	for i := len(c.candles) - 1; i >= 0; i-- {
+		kline := c.candles[i]
+		if kline.CloseTime < t {
+			break
+		}
+		candleVolume := parseDecimal(kline.Volume)
+		if kline.OpenTime < t && kline.CloseTime > kline.OpenTime {
+			candleVolume = candleVolume.Mul(decimal.NewFromInt(kline.CloseTime - t)).Div(decimal.NewFromInt(kline.CloseTime - kline.OpenTime))
+		}
+		volume = volume.Add(candleVolume)
+	}
+	return volume
+}
@@ -2,40 +2,52 @@ package binance_modules
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
 )
 
 type priceLevel struct {
 	price      string
-	buyVolume  float64
-	sellVolume float64
+	buyVolume  decimal.Decimal
+	sellVolume decimal.Decimal
 }
 
 func newPriceLevel(trade *futures.WsAggTradeEvent) priceLevel {
 	result := priceLevel{price: trade.Price}
 
 	if trade.Maker {
-		result.sellVolume, _ = strconv.ParseFloat(trade.Quantity, 64)
+		result.sellVolume = parseDecimal(trade.Quantity)
 	} else {
-		result.buyVolume, _ = strconv.ParseFloat(trade.Quantity, 64)
+		result.buyVolume = parseDecimal(trade.Quantity)
 	}
 
 	return result
 }
 
-func (pl *priceLevel) Quantity() float64 {
-	return pl.buyVolume + pl.sellVolume
+func (pl *priceLevel) Price() decimal.Decimal {
+	return parseDecimal(pl.price)
+}
+
+func (pl *priceLevel) BuyVolume() decimal.Decimal {
+	return pl.buyVolume
+}
+
+func (pl *priceLevel) SellVolume() decimal.Decimal {
+	return pl.sellVolume
+}
+
+func (pl *priceLevel) Quantity() decimal.Decimal {
+	return pl.buyVolume.Add(pl.sellVolume)
 }
 
 func (pl *priceLevel) Update(update *futures.WsAggTradeEvent) {
-	quantity, _ := strconv.ParseFloat(update.Quantity, 64)
+	quantity := parseDecimal(update.Quantity)
 	if update.Maker {
-		pl.sellVolume += quantity
+		pl.sellVolume = pl.sellVolume.Add(quantity)
 	} else {
-		pl.buyVolume += quantity
+		pl.buyVolume = pl.buyVolume.Add(quantity)
 	}
 }
 
@@ -50,30 +62,33 @@ func NewCluster(timeStart, timeEnd int) cluster {
 	return res
 }
 
+// Length returns price range of the cluster in percent
 func (c *cluster) Length() float64 {
 	var (
-		maxPrice float64
-		minPrice float64
+		maxPrice decimal.Decimal
+		minPrice decimal.Decimal
+		first    = true
 	)
 
-	minPrice = -1
-	maxPrice = -1
-
 	for price := range c.levels {
-		fPrice, _ := strconv.ParseFloat(price, 64)
-		if minPrice == -1 {
-			minPrice = fPrice
-			maxPrice = fPrice
+		dPrice := parseDecimal(price)
+		if first {
+			minPrice = dPrice
+			maxPrice = dPrice
+			first = false
 		}
 
-		if fPrice > maxPrice {
-			maxPrice = fPrice
-		} else if fPrice < minPrice {
-			minPrice = fPrice
+		if dPrice.GreaterThan(maxPrice) {
+			maxPrice = dPrice
+		} else if dPrice.LessThan(minPrice) {
+			minPrice = dPrice
 		}
 	}
 
-	return ((maxPrice / minPrice) - 1) * 100
+	if !minPrice.IsPositive() {
+		return 0
+	}
+	return maxPrice.Div(minPrice).Sub(decimal.NewFromInt(1)).Mul(decimal.NewFromInt(100)).InexactFloat64()
 }
 
 func (c *cluster) Update(update *futures.WsAggTradeEvent) bool {
@@ -0,0 +1,221 @@
+package binance_modules
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const minDataGuardCheckInterval = 100 * time.Millisecond
+
+var ErrStaleData = errors.New("market data is stale")
+
+type DataStream string
+
+const (
+	DataStreamDepth     DataStream = "depth"
+	DataStreamKline     DataStream = "kline"
+	DataStreamMarkPrice DataStream = "markPrice"
+	DataStreamAggTrade  DataStream = "aggTrade"
+)
+
+// DataStaleHandler is called when the stream has no fresh events for longer than its threshold
+type DataStaleHandler func(stream DataStream, age time.Duration)
+
+type DataRecoveredHandler func(stream DataStream)
+
+type streamFreshness struct {
+	threshold time.Duration
+	eventTime time.Time     // exchange time of the last event
+	minLag    time.Duration // lowest local receive time minus event time, it absorbs clock offset
+	lagKnown  bool
+	stale     bool
+}
+
+// age returns how old the last event is by local clock corrected for the clock offset
+func (s *streamFreshness) age(now time.Time) time.Duration {
+	return now.Sub(s.eventTime) - s.minLag
+}
+
+// DataGuard tracks freshness of market data streams by their event timestamps.
+// A stream is stale when its last event is older than the threshold or it has no events since Watch.
+type DataGuard struct {
+	lock        sync.Mutex
+	threshold   time.Duration
+	streams     map[DataStream]*streamFreshness
+	onStale     []DataStaleHandler
+	onRecovered []DataRecoveredHandler
+	stopOnce    sync.Once
+	stopC       chan struct{}
+	doneC       chan struct{}
+	log         *Logger
+}
+
+// NewDataGuard creates the guard with default threshold of streams and starts checking them
+func NewDataGuard(threshold time.Duration) (*DataGuard, error) {
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+	guard := &DataGuard{
+		threshold: threshold,
+		streams:   make(map[DataStream]*streamFreshness),
+		stopC:     make(chan struct{}),
+		doneC:     make(chan struct{}),
+		log:       lg,
+	}
+	go guard.run()
+	return guard, nil
+}
+
+// Watch starts tracking the stream, it is stale until the first event if that does not come in time
+func (g *DataGuard) Watch(stream DataStream) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.stream(stream)
+}
+
+// SetThreshold overrides the default threshold for the stream, e.g. klines are sent less often than depth
+func (g *DataGuard) SetThreshold(stream DataStream, threshold time.Duration) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.stream(stream).threshold = threshold
+}
+
+func (g *DataGuard) OnDataStale(handler DataStaleHandler) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.onStale = append(g.onStale, handler)
+}
+
+func (g *DataGuard) OnDataRecovered(handler DataRecoveredHandler) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.onRecovered = append(g.onRecovered, handler)
+}
+
+// Observe takes event time (ms) of a received event, it is called from stream handlers
+func (g *DataGuard) Observe(stream DataStream, eventTime int64) {
+	now := time.Now()
+	g.lock.Lock()
+	freshness := g.stream(stream)
+	event := time.UnixMilli(eventTime)
+	if event.After(freshness.eventTime) {
+		freshness.eventTime = event
+	}
+	lag := now.Sub(event)
+	if !freshness.lagKnown || lag < freshness.minLag {
+		freshness.minLag = lag
+		freshness.lagKnown = true
+	}
+	recovered := freshness.stale && freshness.age(now) <= freshness.threshold
+	if recovered {
+		freshness.stale = false
+	}
+	handlers := g.onRecovered
+	g.lock.Unlock()
+
+	if recovered {
+		g.recovered(stream, handlers)
+	}
+}
+
+// IsStale reports whether any watched stream is stale
+func (g *DataGuard) IsStale() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for _, freshness := range g.streams {
+		if freshness.stale {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *DataGuard) IsStreamStale(stream DataStream) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	freshness, ok := g.streams[stream]
+	return ok && freshness.stale
+}
+
+func (g *DataGuard) Stop() {
+	g.stopOnce.Do(func() { close(g.stopC) })
+	<-g.doneC
+}
+
+// stream returns freshness of the stream, new streams count from now
+func (g *DataGuard) stream(stream DataStream) *streamFreshness {
+	freshness, ok := g.streams[stream]
+	if !ok {
+		freshness = &streamFreshness{threshold: g.threshold, eventTime: time.Now()}
+		g.streams[stream] = freshness
+	}
+	return freshness
+}
+
+func (g *DataGuard) run() {
+	defer close(g.doneC)
+
+	interval := g.threshold / 4
+	if interval < minDataGuardCheckInterval {
+		interval = minDataGuardCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stopC:
+			return
+		case now := <-ticker.C:
+			g.check(now)
+		}
+	}
+}
+
+func (g *DataGuard) check(now time.Time) {
+	type staleStream struct {
+		stream DataStream
+		age    time.Duration
+	}
+	var stale []staleStream
+
+	g.lock.Lock()
+	for stream, freshness := range g.streams {
+		age := freshness.age(now)
+		if !freshness.stale && freshness.threshold > 0 && age > freshness.threshold {
+			freshness.stale = true
+			stale = append(stale, staleStream{stream: stream, age: age})
+		}
+	}
+	handlers := g.onStale
+	g.lock.Unlock()
+
+	for _, s := range stale {
+		g.log.WithFields(logrus.Fields{
+			"stream": s.stream,
+			"age":    s.age.String(),
+		}).Warn("Market data is stale, new orders are blocked")
+		for _, handler := range handlers {
+			handler(s.stream, s.age)
+		}
+	}
+}
+
+func (g *DataGuard) recovered(stream DataStream, handlers []DataRecoveredHandler) {
+	g.log.WithFields(logrus.Fields{
+		"stream": stream,
+	}).Info("Market data recovered")
+	for _, handler := range handlers {
+		handler(stream)
+	}
+}
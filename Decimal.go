@@ -0,0 +1,12 @@
+package binance_modules
+
+import "github.com/shopspring/decimal"
+
+// parseDecimal converts exchange numbers, malformed and empty strings are zero
+func parseDecimal(s string) decimal.Decimal {
+	value, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return value
+}
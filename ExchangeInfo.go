@@ -15,12 +15,14 @@ type ExchangeInfo struct {
 
 var exchangeInfoInstance *ExchangeInfo
 
-func GetExchangeInfo(client *futures.Client) (*ExchangeInfo, error) {
+func GetExchangeInfo(ctx context.Context, client *futures.Client) (*ExchangeInfo, error) {
 	if exchangeInfoInstance == nil {
 		exchangeInfoLock.Lock()
 		defer exchangeInfoLock.Unlock()
 		if exchangeInfoInstance == nil {
-			exInfo, err := client.NewExchangeInfoService().Do(context.Background())
+			exInfo, err := retry(ctx, DefaultRetryPolicy, func(ctx context.Context) (*futures.ExchangeInfo, error) {
+				return client.NewExchangeInfoService().Do(ctx)
+			})
 
 			if err != nil {
 				return exchangeInfoInstance, err
@@ -39,3 +41,14 @@ func (exInfo *ExchangeInfo) Symbol(token string) *futures.Symbol {
 	}
 	return nil
 }
+
+func (exInfo *ExchangeInfo) MarginAsset(token string) string {
+	symbol := exInfo.Symbol(token)
+	if symbol == nil {
+		return ""
+	}
+	if symbol.MarginAsset != "" {
+		return symbol.MarginAsset
+	}
+	return symbol.QuoteAsset
+}
@@ -0,0 +1,320 @@
+package binance_modules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+type ExecutionState string
+
+const (
+	ExecutionStateRunning  ExecutionState = "RUNNING"
+	ExecutionStatePaused   ExecutionState = "PAUSED"
+	ExecutionStateDone     ExecutionState = "DONE" // quantity is executed or the algo is over
+	ExecutionStateCanceled ExecutionState = "CANCELED"
+)
+
+type ExecutionProgress struct {
+	State        ExecutionState
+	Side         futures.SideType
+	Quantity     decimal.Decimal
+	Filled       decimal.Decimal
+	AveragePrice decimal.Decimal
+	ArrivalPrice decimal.Decimal // price when the execution started
+	Shortfall    decimal.Decimal // quantity left unexecuted when the execution is over
+}
+
+func (p ExecutionProgress) Remaining() decimal.Decimal {
+	return p.Quantity.Sub(p.Filled)
+}
+
+// Slippage returns average price versus arrival price in basis points, positive is worse than arrival
+func (p ExecutionProgress) Slippage() decimal.Decimal {
+	if !p.Filled.IsPositive() || !p.ArrivalPrice.IsPositive() {
+		return decimal.Zero
+	}
+	slippage := p.AveragePrice.Sub(p.ArrivalPrice).Div(p.ArrivalPrice).Mul(decimal.NewFromInt(10000))
+	if p.Side == futures.SideTypeSell {
+		return slippage.Neg()
+	}
+	return slippage
+}
+
+// ProgressHandler is called from the execution goroutine when filled quantity or state changes, it must not block
+type ProgressHandler func(progress ExecutionProgress)
+
+// ExecutionRequest is the parent order worked by an execution algo with child orders
+type ExecutionRequest struct {
+	Symbol       futures.Symbol
+	Side         futures.SideType
+	PositionSide futures.PositionSideType
+	Quantity     decimal.Decimal
+	ReduceOnly   bool
+	LimitPrice   decimal.Decimal // buys above and sells below are not executed, zero disables
+	OnProgress   ProgressHandler
+}
+
+func (r *ExecutionRequest) validate() error {
+	if r.Side != futures.SideTypeBuy && r.Side != futures.SideTypeSell {
+		return fmt.Errorf("%w: side %q", ErrInvalidOrderRequest, r.Side)
+	}
+	if !r.Quantity.IsPositive() {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidOrderRequest)
+	}
+	if r.LimitPrice.IsNegative() {
+		return fmt.Errorf("%w: limit price must not be negative", ErrInvalidOrderRequest)
+	}
+	return nil
+}
+
+// execution keeps child orders of an algo and computes its progress from the tracked status
+type execution struct {
+	lock        sync.Mutex
+	provider    *OrderProvider
+	status      *Status
+	request     *ExecutionRequest
+	state       ExecutionState
+	arrival     decimal.Decimal
+	pausedAt    time.Time
+	pausedFor   time.Duration
+	reported    ExecutionProgress
+	childLock   sync.RWMutex // children are read by the stream handler
+	children    map[string]*OrderRequest
+	unsubscribe func()
+	notifyC     chan struct{}
+	stopOnce    sync.Once
+	stopC       chan struct{}
+	doneC       chan struct{}
+	log         *Logger
+}
+
+func (op *OrderProvider) newExecution(request *ExecutionRequest) (*execution, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+	status := op.tracked(request.Symbol.Symbol)
+	if status == nil {
+		return nil, ErrNotTracked
+	}
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &execution{
+		provider: op,
+		status:   status,
+		request:  request,
+		state:    ExecutionStateRunning,
+		arrival:  status.MarkPrice(),
+		children: make(map[string]*OrderRequest),
+		notifyC:  make(chan struct{}, 1),
+		stopC:    make(chan struct{}),
+		doneC:    make(chan struct{}),
+		log:      lg,
+	}
+	e.unsubscribe = status.OnOrderUpdate(e.orderHandler)
+	return e, nil
+}
+
+func (e *execution) State() ExecutionState {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.state
+}
+
+func (e *execution) Progress() ExecutionProgress {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.progress()
+}
+
+// Done is closed when the execution is over, canceled or its context is done
+func (e *execution) Done() <-chan struct{} {
+	return e.doneC
+}
+
+// Pause stops sending new child orders until Resume
+func (e *execution) Pause() {
+	e.lock.Lock()
+	if e.state == ExecutionStateRunning {
+		e.state = ExecutionStatePaused
+		e.pausedAt = time.Now()
+	}
+	e.lock.Unlock()
+	e.notify()
+}
+
+func (e *execution) Resume() {
+	e.lock.Lock()
+	if e.state == ExecutionStatePaused {
+		e.state = ExecutionStateRunning
+		e.pausedFor += time.Since(e.pausedAt)
+	}
+	e.lock.Unlock()
+	e.notify()
+}
+
+// Cancel stops the execution and cancels child orders which are still open
+func (e *execution) Cancel(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stopC) })
+	<-e.doneC
+
+	e.lock.Lock()
+	err := e.cancelChildren(ctx)
+	if e.state != ExecutionStateDone {
+		e.state = ExecutionStateCanceled
+	}
+	e.lock.Unlock()
+
+	e.report()
+	return err
+}
+
+func (e *execution) orderHandler(order *futures.Order) {
+	e.childLock.RLock()
+	defer e.childLock.RUnlock()
+
+	if _, ok := e.children[order.ClientOrderID]; ok {
+		e.notify()
+	}
+}
+
+func (e *execution) notify() {
+	select {
+	case e.notifyC <- struct{}{}:
+	default:
+	}
+}
+
+func (e *execution) finish() {
+	e.unsubscribe()
+	close(e.doneC)
+}
+
+// report calls the progress handler when something changed since the last call
+func (e *execution) report() {
+	e.lock.Lock()
+	progress := e.progress()
+	changed := progress.State != e.reported.State || !progress.Filled.Equal(e.reported.Filled)
+	e.reported = progress
+	e.lock.Unlock()
+
+	if changed && e.request.OnProgress != nil {
+		e.request.OnProgress(progress)
+	}
+}
+
+func (e *execution) progress() ExecutionProgress {
+	e.childLock.RLock()
+	defer e.childLock.RUnlock()
+
+	filled, quote := decimal.Zero, decimal.Zero
+	for clientOrderID := range e.children {
+		order := e.status.Orders.GetByClientID(clientOrderID)
+		if order == nil {
+			continue
+		}
+		executed := parseDecimal(order.ExecutedQuantity)
+		filled = filled.Add(executed)
+		quote = quote.Add(executed.Mul(parseDecimal(order.AvgPrice)))
+	}
+
+	progress := ExecutionProgress{
+		State:        e.state,
+		Side:         e.request.Side,
+		Quantity:     e.request.Quantity,
+		Filled:       filled,
+		AveragePrice: decimal.Zero,
+		ArrivalPrice: e.arrival,
+	}
+	if filled.IsPositive() {
+		progress.AveragePrice = quote.Div(filled)
+	}
+	progress.Shortfall = decimal.Zero
+	if e.state == ExecutionStateDone || e.state == ExecutionStateCanceled {
+		progress.Shortfall = progress.Remaining()
+	}
+	return progress
+}
+
+// inflight returns quantity of child orders which may still be filled
+func (e *execution) inflight() decimal.Decimal {
+	e.childLock.RLock()
+	defer e.childLock.RUnlock()
+
+	quantity := decimal.Zero
+	for clientOrderID, request := range e.children {
+		order := e.status.Orders.GetByClientID(clientOrderID)
+		switch {
+		case order == nil && e.status.Orders.IsPending(clientOrderID):
+			quantity = quantity.Add(request.Quantity)
+		case order != nil && !IsTerminalOrderStatus(order.Status):
+			quantity = quantity.Add(parseDecimal(order.OrigQuantity).Sub(parseDecimal(order.ExecutedQuantity)))
+		}
+	}
+	return quantity
+}
+
+// executable rounds quantity down to lot size, false means it is below the minimum
+func (e *execution) executable(orderType futures.OrderType, quantity decimal.Decimal) (decimal.Decimal, bool) {
+	request := NewOrderRequest(e.request.Symbol, e.request.Side, orderType)
+	_, filter := lotSizeFilter(request)
+	if filter == nil {
+		return quantity, quantity.IsPositive()
+	}
+	quantity = roundToStep(quantity, filter.StepSize, RoundFloor)
+	return quantity, quantity.IsPositive() && quantity.GreaterThanOrEqual(parseDecimal(filter.MinQuantity))
+}
+
+func (e *execution) submit(ctx context.Context, request *OrderRequest) error {
+	request.WithPositionSide(e.request.PositionSide).
+		WithClientOrderID(e.provider.NewClientOrderID())
+	if e.request.ReduceOnly {
+		request.ReduceOnly()
+	}
+	e.childLock.Lock()
+	e.children[request.ClientOrderID] = request
+	e.childLock.Unlock()
+
+	_, err := e.provider.Submit(ctx, request)
+	if err != nil {
+		e.log.WithFields(logrus.Fields{
+			"symbol":        e.request.Symbol.Symbol,
+			"clientOrderID": request.ClientOrderID,
+			"quantity":      request.Quantity.String(),
+			"err":           err.Error(),
+		}).Warn("Failed to submit child order")
+	}
+	return err
+}
+
+func (e *execution) cancelChildren(ctx context.Context) error {
+	e.childLock.RLock()
+	defer e.childLock.RUnlock()
+
+	var firstErr error
+	for clientOrderID := range e.children {
+		order := e.status.Orders.GetByClientID(clientOrderID)
+		if order == nil && !e.status.Orders.IsPending(clientOrderID) {
+			continue
+		}
+		if order != nil && IsTerminalOrderStatus(order.Status) {
+			continue
+		}
+		_, err := e.provider.CancelClientOrder(ctx, e.request.Symbol, clientOrderID)
+		if err != nil && !isOrderNotOpen(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
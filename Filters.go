@@ -0,0 +1,193 @@
+package binance_modules
+
+import (
+	"fmt"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+type RoundingMode int
+
+const (
+	RoundNearest RoundingMode = iota
+	RoundFloor
+	RoundCeil
+)
+
+type Rounding struct {
+	Price    RoundingMode
+	Quantity RoundingMode
+}
+
+// FilterError describes an order rejected by a symbol filter, it wraps ErrInvalidOrderRequest
+type FilterError struct {
+	Filter futures.SymbolFilterType
+	Field  string
+	Value  decimal.Decimal
+	Limit  decimal.Decimal
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s: %s %s %s %s", e.Filter, e.Field, e.Value, e.Reason, e.Limit)
+}
+
+func (e *FilterError) Unwrap() error {
+	return ErrInvalidOrderRequest
+}
+
+// OrderValidator normalizes prices and quantities to symbol steps and checks them against exchange filters
+type OrderValidator struct {
+	Buy  Rounding
+	Sell Rounding
+}
+
+// NewOrderValidator rounds prices to the passive side and quantities down
+func NewOrderValidator() *OrderValidator {
+	return &OrderValidator{
+		Buy:  Rounding{Price: RoundFloor, Quantity: RoundFloor},
+		Sell: Rounding{Price: RoundCeil, Quantity: RoundFloor},
+	}
+}
+
+// Validate rounds the request in place, markPrice and openOrders are skipped when zero
+func (v *OrderValidator) Validate(r *OrderRequest, markPrice decimal.Decimal, openOrders int) error {
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+
+	rounding := v.Buy
+	if r.Side == futures.SideTypeSell {
+		rounding = v.Sell
+	}
+
+	if filter := r.Symbol.PriceFilter(); filter != nil {
+		prices := []struct {
+			field string
+			value *decimal.Decimal
+		}{
+			{"price", &r.Price},
+			{"stopPrice", &r.StopPrice},
+			{"activationPrice", &r.ActivationPrice},
+		}
+		for _, price := range prices {
+			if price.value.IsZero() {
+				continue
+			}
+			*price.value = roundToStep(*price.value, filter.TickSize, rounding.Price)
+			err = checkRange(futures.SymbolFilterTypePrice, price.field, *price.value, filter.MinPrice, filter.MaxPrice)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !r.IsClosePosition {
+		filterType, filter := lotSizeFilter(r)
+		if filter != nil {
+			original := r.Quantity
+			r.Quantity = roundToStep(r.Quantity, filter.StepSize, rounding.Quantity)
+			if !r.Quantity.IsPositive() {
+				return &FilterError{Filter: filterType, Field: "quantity", Value: original, Limit: parseDecimal(filter.StepSize), Reason: "rounds to zero with step"}
+			}
+			err = checkRange(filterType, "quantity", r.Quantity, filter.MinQuantity, filter.MaxQuantity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	price := r.Price
+	if price.IsZero() {
+		price = markPrice
+	}
+	if filter := r.Symbol.MinNotionalFilter(); filter != nil && price.IsPositive() && !r.IsReduceOnly && !r.IsClosePosition {
+		minNotional := parseDecimal(filter.Notional)
+		notional := price.Mul(r.Quantity)
+		if notional.LessThan(minNotional) {
+			return &FilterError{Filter: futures.SymbolFilterTypeMinNotional, Field: "notional", Value: notional, Limit: minNotional, Reason: "is below min"}
+		}
+	}
+
+	if filter := r.Symbol.PercentPriceFilter(); filter != nil && r.Price.IsPositive() && markPrice.IsPositive() {
+		if r.Side == futures.SideTypeBuy {
+			limit := markPrice.Mul(parseDecimal(filter.MultiplierUp))
+			if limit.IsPositive() && r.Price.GreaterThan(limit) {
+				return &FilterError{Filter: futures.SymbolFilterTypePercentPrice, Field: "price", Value: r.Price, Limit: limit, Reason: "is above"}
+			}
+		} else {
+			limit := markPrice.Mul(parseDecimal(filter.MultiplierDown))
+			if r.Price.LessThan(limit) {
+				return &FilterError{Filter: futures.SymbolFilterTypePercentPrice, Field: "price", Value: r.Price, Limit: limit, Reason: "is below"}
+			}
+		}
+	}
+
+	if filter := r.Symbol.MaxNumOrdersFilter(); filter != nil && filter.Limit > 0 && int64(openOrders) >= filter.Limit {
+		return &FilterError{Filter: futures.SymbolFilterTypeMaxNumOrders, Field: "open orders", Value: decimal.NewFromInt(int64(openOrders)), Limit: decimal.NewFromInt(filter.Limit), Reason: "reached limit"}
+	}
+	return nil
+}
+
+// lotSizeFilter returns MARKET_LOT_SIZE for orders executed as market ones if symbol has it
+func lotSizeFilter(r *OrderRequest) (futures.SymbolFilterType, *futures.LotSizeFilter) {
+	switch r.Type {
+	case futures.OrderTypeMarket, futures.OrderTypeStopMarket, futures.OrderTypeTakeProfitMarket, futures.OrderTypeTrailingStopMarket:
+		if filter := r.Symbol.MarketLotSizeFilter(); filter != nil {
+			lotSize := futures.LotSizeFilter(*filter)
+			return futures.SymbolFilterTypeMarketLotSize, &lotSize
+		}
+	}
+	return futures.SymbolFilterTypeLotSize, r.Symbol.LotSizeFilter()
+}
+
+func checkRange(filterType futures.SymbolFilterType, field string, value decimal.Decimal, min, max string) error {
+	minValue := parseDecimal(min)
+	maxValue := parseDecimal(max)
+	if value.LessThan(minValue) {
+		return &FilterError{Filter: filterType, Field: field, Value: value, Limit: minValue, Reason: "is below min"}
+	}
+	// zero max means no limit
+	if maxValue.IsPositive() && value.GreaterThan(maxValue) {
+		return &FilterError{Filter: filterType, Field: field, Value: value, Limit: maxValue, Reason: "is above max"}
+	}
+	return nil
+}
+
+// roundToStep rounds value to a multiple of step, step like "0.5" or "10" is supported
+func roundToStep(value decimal.Decimal, step string, mode RoundingMode) decimal.Decimal {
+	stepSize, err := decimal.NewFromString(step)
+	if err != nil || !stepSize.IsPositive() {
+		return value
+	}
+
+	steps := value.Div(stepSize)
+	switch mode {
+	case RoundFloor:
+		steps = steps.Floor()
+	case RoundCeil:
+		steps = steps.Ceil()
+	default:
+		steps = steps.Round(0)
+	}
+	return steps.Mul(stepSize)
+}
+
+// stepPlaces returns number of significant decimal places of step
+func stepPlaces(step string) int32 {
+	stepSize, err := decimal.NewFromString(step)
+	if err != nil {
+		return 0
+	}
+	var places int32
+	for places < 18 && !stepSize.Shift(places).IsInteger() {
+		places++
+	}
+	return places
+}
+
+func formatStep(value decimal.Decimal, step string, mode RoundingMode) string {
+	return roundToStep(value, step, mode).StringFixed(stepPlaces(step))
+}
@@ -0,0 +1,163 @@
+package binance_modules
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+func TestRoundToStep(t *testing.T) {
+	tests := []struct {
+		value string
+		step  string
+		mode  RoundingMode
+		want  string
+	}{
+		{"1.2345", "0.01", RoundNearest, "1.23"},
+		{"1.235", "0.01", RoundNearest, "1.24"},
+		{"1.2399", "0.01", RoundFloor, "1.23"},
+		{"1.2301", "0.01", RoundCeil, "1.24"},
+		{"1.23", "0.01", RoundCeil, "1.23"},
+		{"103.7", "0.5", RoundFloor, "103.5"},
+		{"103.7", "0.5", RoundCeil, "104"},
+		{"1234", "10", RoundNearest, "1230"},
+		{"1235", "10", RoundFloor, "1230"},
+		{"0.0009", "0.001", RoundFloor, "0"},
+		{"0.1", "0.1", RoundNearest, "0.1"},
+		{"1.2345", "0", RoundFloor, "1.2345"},
+		{"1.2345", "", RoundFloor, "1.2345"},
+	}
+	for _, test := range tests {
+		got := roundToStep(decimal.RequireFromString(test.value), test.step, test.mode)
+		if !got.Equal(decimal.RequireFromString(test.want)) {
+			t.Errorf("roundToStep(%s, %q, %d) = %s, want %s", test.value, test.step, test.mode, got, test.want)
+		}
+	}
+}
+
+func TestFormatStep(t *testing.T) {
+	tests := []struct {
+		value string
+		step  string
+		want  string
+	}{
+		{"1.2", "0.010", "1.20"},
+		{"0.12345", "0.001", "0.123"},
+		{"1234.5", "10", "1230"},
+		{"3", "0.5", "3.0"},
+	}
+	for _, test := range tests {
+		got := formatStep(decimal.RequireFromString(test.value), test.step, RoundNearest)
+		if got != test.want {
+			t.Errorf("formatStep(%s, %q) = %s, want %s", test.value, test.step, got, test.want)
+		}
+	}
+}
+
+func testSymbol() futures.Symbol {
+	return futures.Symbol{
+		Symbol: "BTCUSDT",
+		Filters: []map[string]interface{}{
+			{"filterType": "PRICE_FILTER", "minPrice": "0.10", "maxPrice": "100000", "tickSize": "0.10"},
+			{"filterType": "LOT_SIZE", "minQty": "0.001", "maxQty": "1000", "stepSize": "0.001"},
+			{"filterType": "MARKET_LOT_SIZE", "minQty": "0.001", "maxQty": "100", "stepSize": "0.001"},
+			{"filterType": "MIN_NOTIONAL", "notional": "5"},
+			{"filterType": "MAX_NUM_ORDERS", "limit": float64(200)},
+		},
+	}
+}
+
+func TestOrderValidator(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *OrderRequest
+		markPrice    string
+		openOrders   int
+		wantFilter   futures.SymbolFilterType
+		wantPrice    string
+		wantQuantity string
+	}{
+		{
+			name:         "buy rounds price and quantity down",
+			request:      NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(100.17).WithQuantityFloat(0.1239),
+			wantPrice:    "100.1",
+			wantQuantity: "0.123",
+		},
+		{
+			name:         "sell rounds price up",
+			request:      NewOrderRequest(testSymbol(), futures.SideTypeSell, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(100.11).WithQuantityFloat(0.1),
+			wantPrice:    "100.2",
+			wantQuantity: "0.1",
+		},
+		{
+			name:       "quantity rounding to zero",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeMarket).WithQuantityFloat(0.0009),
+			markPrice:  "100",
+			wantFilter: futures.SymbolFilterTypeMarketLotSize,
+		},
+		{
+			name:       "market quantity above market lot size",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeMarket).WithQuantityFloat(500),
+			markPrice:  "100",
+			wantFilter: futures.SymbolFilterTypeMarketLotSize,
+		},
+		{
+			name:       "price below min",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(0.01).WithQuantityFloat(1),
+			wantFilter: futures.SymbolFilterTypePrice,
+		},
+		{
+			name:       "notional below min",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(100).WithQuantityFloat(0.01),
+			wantFilter: futures.SymbolFilterTypeMinNotional,
+		},
+		{
+			name:         "reduce-only order skips min notional",
+			request:      NewOrderRequest(testSymbol(), futures.SideTypeSell, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(100).WithQuantityFloat(0.01).ReduceOnly(),
+			wantPrice:    "100",
+			wantQuantity: "0.01",
+		},
+		{
+			name:       "market notional by mark price",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeMarket).WithQuantityFloat(0.01),
+			markPrice:  "100",
+			wantFilter: futures.SymbolFilterTypeMinNotional,
+		},
+		{
+			name:       "open orders limit",
+			request:    NewOrderRequest(testSymbol(), futures.SideTypeBuy, futures.OrderTypeLimit).WithTimeInForce(futures.TimeInForceTypeGTC).WithPriceFloat(100).WithQuantityFloat(1),
+			openOrders: 200,
+			wantFilter: futures.SymbolFilterTypeMaxNumOrders,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			markPrice := decimal.Zero
+			if test.markPrice != "" {
+				markPrice = decimal.RequireFromString(test.markPrice)
+			}
+			err := NewOrderValidator().Validate(test.request, markPrice, test.openOrders)
+			if test.wantFilter != "" {
+				var filterErr *FilterError
+				if !errors.As(err, &filterErr) || filterErr.Filter != test.wantFilter {
+					t.Fatalf("error = %v, want %s filter error", err, test.wantFilter)
+				}
+				if !errors.Is(err, ErrInvalidOrderRequest) {
+					t.Errorf("error %v does not wrap ErrInvalidOrderRequest", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.wantPrice != "" && !test.request.Price.Equal(decimal.RequireFromString(test.wantPrice)) {
+				t.Errorf("price = %s, want %s", test.request.Price, test.wantPrice)
+			}
+			if test.wantQuantity != "" && !test.request.Quantity.Equal(decimal.RequireFromString(test.wantQuantity)) {
+				t.Errorf("quantity = %s, want %s", test.request.Quantity, test.wantQuantity)
+			}
+		})
+	}
+}
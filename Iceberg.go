@@ -0,0 +1,269 @@
+package binance_modules
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultRepegInterval = time.Second
+
+// IcebergRequest works the parent order with a small post-only order pegged to the top of book
+type IcebergRequest struct {
+	ExecutionRequest
+	VisibleQuantity decimal.Decimal
+	PegOffset       int           // ticks behind the best price of the own side, 0 joins it
+	RepegInterval   time.Duration // min time between amendments of the visible order
+	CrossAfter      time.Duration // remaining quantity is taken from the book after it, zero disables
+}
+
+func (r *IcebergRequest) validate() error {
+	if !r.VisibleQuantity.IsPositive() {
+		return fmt.Errorf("%w: visible quantity must be positive", ErrInvalidOrderRequest)
+	}
+	if r.PegOffset < 0 || r.RepegInterval < 0 || r.CrossAfter < 0 {
+		return fmt.Errorf("%w: peg offset and intervals must not be negative", ErrInvalidOrderRequest)
+	}
+	return nil
+}
+
+// IcebergExecution keeps one GTX order at the best bid for buys or the best ask for sells,
+// moves it after the book and replaces it when it is filled. Pause pulls the visible order.
+// Arrival price is the mid price of the book when the execution starts.
+type IcebergExecution struct {
+	*execution
+	iceberg    *IcebergRequest
+	start      time.Time
+	visibleID  string
+	canceledID string // visible order whose cancel is accepted, it is not sent again
+	lastAction time.Time
+	crossed    bool
+	bookLock   sync.Mutex // book is written by the depth stream
+	bid        decimal.Decimal
+	ask        decimal.Decimal
+}
+
+// Iceberg starts the execution, the caller feeds depth updates by OrderBookUpdate
+func (op *OrderProvider) Iceberg(ctx context.Context, request *IcebergRequest, orderbook *OrderBook) (*IcebergExecution, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(orderbook.Bids) == 0 || len(orderbook.Asks) == 0 {
+		return nil, fmt.Errorf("%w: order book is empty", ErrInvalidOrderRequest)
+	}
+	e, err := op.newExecution(&request.ExecutionRequest)
+	if err != nil {
+		return nil, err
+	}
+	if request.RepegInterval == 0 {
+		request.RepegInterval = defaultRepegInterval
+	}
+	e.arrival = orderbook.MidPrice()
+
+	iceberg := &IcebergExecution{
+		execution: e,
+		iceberg:   request,
+		start:     time.Now(),
+		bid:       orderbook.BestBidLevel().Price,
+		ask:       orderbook.BestAskLevel().Price,
+	}
+	go iceberg.run(ctx)
+	return iceberg, nil
+}
+
+// OrderBookUpdate takes the top of book, it does not block the stream
+func (e *IcebergExecution) OrderBookUpdate(orderbook *OrderBook) {
+	if len(orderbook.Bids) == 0 || len(orderbook.Asks) == 0 {
+		return
+	}
+	e.bookLock.Lock()
+	e.bid = orderbook.BestBidLevel().Price
+	e.ask = orderbook.BestAskLevel().Price
+	e.bookLock.Unlock()
+	e.notify()
+}
+
+func (e *IcebergExecution) run(ctx context.Context) {
+	defer e.finish()
+
+	ticker := time.NewTicker(e.iceberg.RepegInterval)
+	defer ticker.Stop()
+
+	for {
+		if e.work(ctx) {
+			e.report()
+			return
+		}
+		e.report()
+
+		select {
+		case <-ctx.Done():
+			e.lock.Lock()
+			e.state = ExecutionStateCanceled
+			e.lock.Unlock()
+			e.pull(context.Background())
+		case <-e.stopC:
+			return
+		case <-ticker.C:
+		case <-e.notifyC:
+		}
+	}
+}
+
+// work moves the execution one step further, returns true when it is over
+func (e *IcebergExecution) work(ctx context.Context) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if ctx.Err() != nil || e.state == ExecutionStateCanceled || e.state == ExecutionStateDone {
+		return true
+	}
+	inflight := e.inflight()
+	remaining := e.progress().Remaining()
+	_, executable := e.executable(futures.OrderTypeLimit, remaining)
+	if inflight.IsZero() && (e.crossed || !executable) {
+		e.state = ExecutionStateDone
+		return true
+	}
+	if e.crossed {
+		return false
+	}
+	if e.state == ExecutionStatePaused {
+		e.cancelVisible(ctx)
+		return false
+	}
+	if e.iceberg.CrossAfter > 0 && time.Since(e.start)-e.pausedFor >= e.iceberg.CrossAfter {
+		e.cross(ctx)
+		return false
+	}
+
+	price, ok := e.pegPrice()
+	if !ok {
+		return false
+	}
+	visible := e.status.Orders.GetByClientID(e.visibleID)
+	if e.visibleID != "" && (visible == nil || !IsTerminalOrderStatus(visible.Status)) {
+		if visible != nil && e.canceledID != e.visibleID && !parseDecimal(visible.Price).Equal(price) && time.Since(e.lastAction) >= e.iceberg.RepegInterval {
+			e.repeg(ctx, visible, price)
+		}
+		return false
+	}
+	// GTX orders expire when they would cross, don't resend them on every book update
+	if visible != nil && visible.Status != futures.OrderStatusTypeFilled && time.Since(e.lastAction) < e.iceberg.RepegInterval {
+		return false
+	}
+
+	quantity, ok := e.executable(futures.OrderTypeLimit, decimal.Min(e.iceberg.VisibleQuantity, remaining.Sub(inflight)))
+	if !ok {
+		return false
+	}
+	request := NewOrderRequest(e.request.Symbol, e.request.Side, futures.OrderTypeLimit).
+		WithQuantity(quantity).
+		WithPrice(price).
+		PostOnly()
+	e.lastAction = time.Now()
+	if e.submit(ctx, request) == nil {
+		e.visibleID = request.ClientOrderID
+	}
+	return false
+}
+
+// pegPrice returns price behind the best one of the own side, limited by the limit price
+func (e *IcebergExecution) pegPrice() (decimal.Decimal, bool) {
+	e.bookLock.Lock()
+	bid, ask := e.bid, e.ask
+	e.bookLock.Unlock()
+
+	tick := decimal.Zero
+	if filter := e.request.Symbol.PriceFilter(); filter != nil {
+		tick = parseDecimal(filter.TickSize)
+	}
+	offset := tick.Mul(decimal.NewFromInt(int64(e.iceberg.PegOffset)))
+
+	if e.request.Side == futures.SideTypeBuy {
+		price := bid.Sub(offset)
+		if e.request.LimitPrice.IsPositive() {
+			price = decimal.Min(price, e.request.LimitPrice)
+		}
+		return price, price.IsPositive()
+	}
+	price := ask.Add(offset)
+	if e.request.LimitPrice.IsPositive() {
+		price = decimal.Max(price, e.request.LimitPrice)
+	}
+	return price, price.IsPositive()
+}
+
+func (e *IcebergExecution) repeg(ctx context.Context, visible *futures.Order, price decimal.Decimal) {
+	e.lastAction = time.Now()
+	_, err := e.provider.ModifyClientOrder(ctx, e.request.Symbol, visible.ClientOrderID, e.request.Side, parseDecimal(visible.OrigQuantity), price)
+	if err == nil {
+		return
+	}
+	e.log.WithFields(logrus.Fields{
+		"symbol":        e.request.Symbol.Symbol,
+		"clientOrderID": visible.ClientOrderID,
+		"price":         price.String(),
+		"err":           err.Error(),
+	}).Warn("Failed to re-peg visible order, replacing it")
+	// a new order is placed when the canceled one is terminal
+	e.cancelVisible(ctx)
+}
+
+// cross cancels the visible order and takes the remaining quantity, LIMIT IOC keeps the limit price
+func (e *IcebergExecution) cross(ctx context.Context) {
+	if !e.cancelVisible(ctx) {
+		return
+	}
+	quantity, ok := e.executable(futures.OrderTypeMarket, e.progress().Remaining().Sub(e.inflight()))
+	e.crossed = true
+	if !ok {
+		return
+	}
+
+	request := NewOrderRequest(e.request.Symbol, e.request.Side, futures.OrderTypeMarket).WithQuantity(quantity)
+	if e.request.LimitPrice.IsPositive() {
+		request = NewOrderRequest(e.request.Symbol, e.request.Side, futures.OrderTypeLimit).
+			WithQuantity(quantity).
+			WithPrice(e.request.LimitPrice).
+			WithTimeInForce(futures.TimeInForceTypeIOC)
+	}
+	e.lastAction = time.Now()
+	e.submit(ctx, request)
+}
+
+// cancelVisible returns true when there is no open visible order anymore or its cancel is accepted,
+// the cancel is sent once and the stream confirms it later
+func (e *IcebergExecution) cancelVisible(ctx context.Context) bool {
+	if e.visibleID == "" || e.canceledID == e.visibleID {
+		return true
+	}
+	visible := e.status.Orders.GetByClientID(e.visibleID)
+	if visible != nil && IsTerminalOrderStatus(visible.Status) {
+		return true
+	}
+	_, err := e.provider.CancelClientOrder(ctx, e.request.Symbol, e.visibleID)
+	if err != nil && !isOrderNotOpen(err) {
+		e.log.WithFields(logrus.Fields{
+			"symbol":        e.request.Symbol.Symbol,
+			"clientOrderID": e.visibleID,
+			"err":           err.Error(),
+		}).Warn("Failed to cancel visible order")
+		return false
+	}
+	e.canceledID = e.visibleID
+	return true
+}
+
+func (e *IcebergExecution) pull(ctx context.Context) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.cancelVisible(ctx)
+}
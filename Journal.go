@@ -0,0 +1,154 @@
+package binance_modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+type Fill struct {
+	Time            int64                    `json:"time"` // ms
+	Symbol          string                   `json:"symbol"`
+	Strategy        string                   `json:"strategy"`
+	OrderID         int64                    `json:"orderId"`
+	ClientOrderID   string                   `json:"clientOrderId"`
+	TradeID         int64                    `json:"tradeId"`
+	Side            futures.SideType         `json:"side"`
+	PositionSide    futures.PositionSideType `json:"positionSide"`
+	Type            futures.OrderType        `json:"type"`
+	Price           string                   `json:"price"`
+	Quantity        string                   `json:"quantity"`
+	Maker           bool                     `json:"maker"`
+	Commission      string                   `json:"commission"`
+	CommissionAsset string                   `json:"commissionAsset"`
+	RealizedPnL     string                   `json:"realizedPnl"`
+}
+
+type fillKey struct {
+	symbol  string
+	tradeID int64
+}
+
+// Journal is an append-only log of executions, persisted as JSON lines
+type Journal struct {
+	lock   sync.RWMutex
+	fills  []*Fill
+	known  map[fillKey]bool
+	file   *os.File
+	writer *json.Encoder
+}
+
+var journalLock = sync.Mutex{}
+var journalInstance *Journal
+
+func GetJournal() (*Journal, error) {
+	if journalInstance == nil {
+		journalLock.Lock()
+		defer journalLock.Unlock()
+		if journalInstance == nil {
+			journal, err := NewJournal("BM_fills.jsonl")
+			if err != nil {
+				return journalInstance, err
+			}
+			journalInstance = journal
+		}
+	}
+	return journalInstance, nil
+}
+
+func NewJournal(filename string) (*Journal, error) {
+	journal := new(Journal)
+	journal.known = make(map[fillKey]bool)
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return journal, err
+	}
+
+	// restore fills recorded before restart
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fill := new(Fill)
+		if json.Unmarshal(scanner.Bytes(), fill) != nil {
+			continue
+		}
+		journal.fills = append(journal.fills, fill)
+		journal.known[fillKey{fill.Symbol, fill.TradeID}] = true
+	}
+	if err = scanner.Err(); err != nil {
+		file.Close()
+		return journal, err
+	}
+
+	journal.file = file
+	journal.writer = json.NewEncoder(file)
+	return journal, nil
+}
+
+func (j *Journal) Record(fill *Fill) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	key := fillKey{fill.Symbol, fill.TradeID}
+	if j.known[key] {
+		return nil
+	}
+	err := j.writer.Encode(fill)
+	if err != nil {
+		return err
+	}
+	j.fills = append(j.fills, fill)
+	j.known[key] = true
+	return nil
+}
+
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.file.Close()
+}
+
+func (j *Journal) filter(match func(fill *Fill) bool) []*Fill {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+
+	var fills []*Fill
+	for _, fill := range j.fills {
+		if match(fill) {
+			fills = append(fills, fill)
+		}
+	}
+	return fills
+}
+
+func (j *Journal) Fills() []*Fill {
+	return j.filter(func(fill *Fill) bool { return true })
+}
+
+func (j *Journal) ByOrder(orderID int64) []*Fill {
+	return j.filter(func(fill *Fill) bool { return fill.OrderID == orderID })
+}
+
+func (j *Journal) ByClientOrder(clientOrderID string) []*Fill {
+	return j.filter(func(fill *Fill) bool { return fill.ClientOrderID == clientOrderID })
+}
+
+func (j *Journal) ByStrategy(strategy string) []*Fill {
+	return j.filter(func(fill *Fill) bool { return fill.Strategy == strategy })
+}
+
+func (j *Journal) BySymbol(symbol string) []*Fill {
+	return j.filter(func(fill *Fill) bool { return fill.Symbol == symbol })
+}
+
+// Between returns fills executed in [from, to)
+func (j *Journal) Between(from, to time.Time) []*Fill {
+	return j.filter(func(fill *Fill) bool {
+		return fill.Time >= from.UnixMilli() && fill.Time < to.UnixMilli()
+	})
+}
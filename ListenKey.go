@@ -26,7 +26,7 @@ func GetListenKey(client *futures.Client) (*ListenKey, error) {
 			if err != nil {
 				return listenKeyInstance, err
 			}
-			listenKeyInstance = &ListenKey{listenKey: lk}
+			listenKeyInstance = &ListenKey{listenKey: lk, client: client}
 			go listenKeyInstance.Renew()
 		}
 	}
@@ -40,11 +40,33 @@ func (lk *ListenKey) Renew() {
 	timeout := lastUpdateTime.Add(55 * time.Minute)
 	for {
 		if time.Now().After(timeout) {
-			err := lk.client.NewKeepaliveUserStreamService().ListenKey(lk.listenKey).Do(context.Background())
+			err := lk.client.NewKeepaliveUserStreamService().ListenKey(lk.Key()).Do(context.Background())
 			if err == nil {
 				lastUpdateTime = time.Now()
 				timeout = lastUpdateTime.Add(55 * time.Minute)
 			}
 		}
+		time.Sleep(time.Second)
 	}
 }
+
+// Key returns the current listenKey, it is replaced by Restart
+func (lk *ListenKey) Key() string {
+	listenKeyLock.Lock()
+	defer listenKeyLock.Unlock()
+
+	return lk.listenKey
+}
+
+func (lk *ListenKey) Restart() error {
+	// request new listenKey after the previous one has expired
+	listenKeyLock.Lock()
+	defer listenKeyLock.Unlock()
+
+	key, err := lk.client.NewStartUserStreamService().Do(context.Background())
+	if err != nil {
+		return err
+	}
+	lk.listenKey = key
+	return nil
+}
@@ -0,0 +1,80 @@
+package binance_modules
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+type accountAsset struct {
+	Asset            string `json:"asset"`
+	WalletBalance    string `json:"walletBalance"`
+	MarginBalance    string `json:"marginBalance"`
+	AvailableBalance string `json:"availableBalance"`
+}
+
+type accountMargin struct {
+	TotalMarginBalance string         `json:"totalMarginBalance"`
+	AvailableBalance   string         `json:"availableBalance"`
+	Assets             []accountAsset `json:"assets"`
+}
+
+func getMultiAssetsMode(ctx context.Context, client *futures.Client) (bool, error) {
+	var mode struct {
+		MultiAssetsMargin bool `json:"multiAssetsMargin"`
+	}
+	data, err := signedRequest(ctx, client, http.MethodGet, "/fapi/v1/multiAssetsMargin", nil)
+	if err != nil {
+		return false, err
+	}
+	err = json.Unmarshal(data, &mode)
+	return mode.MultiAssetsMargin, err
+}
+
+func getAccountMargin(ctx context.Context, client *futures.Client) (*accountMargin, error) {
+	margin := new(accountMargin)
+	data, err := signedRequest(ctx, client, http.MethodGet, "/fapi/v2/account", nil)
+	if err != nil {
+		return margin, err
+	}
+	err = json.Unmarshal(data, margin)
+	return margin, err
+}
+
+func (s *Status) setMargin(margin *accountMargin) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.MultiAssets {
+		// in multi-assets mode all totals are in USD value of collateral
+		s.TotalMargin = margin.TotalMarginBalance
+		s.AvailableMargin = margin.AvailableBalance
+		return
+	}
+	for _, asset := range margin.Assets {
+		if asset.Asset == s.Balance.Asset {
+			s.TotalMargin = asset.MarginBalance
+			s.AvailableMargin = asset.AvailableBalance
+			return
+		}
+	}
+	s.TotalMargin = "0"
+	s.AvailableMargin = "0"
+}
+
+func (s *Status) setCollateral(balances []*futures.Balance) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Collateral = make(map[string]*futures.Balance)
+	for _, balance := range balances {
+		if balance.Asset == s.Balance.Asset {
+			s.Balance = balance
+		}
+		if s.MultiAssets || balance.Asset == s.Balance.Asset {
+			s.Collateral[balance.Asset] = balance
+		}
+	}
+}
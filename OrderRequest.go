@@ -0,0 +1,207 @@
+package binance_modules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidOrderRequest = errors.New("invalid order request")
+
+// OrderRequest describes an order of any type, it is built with chained setters and sent by OrderProvider.Submit
+type OrderRequest struct {
+	Symbol          futures.Symbol
+	Side            futures.SideType
+	PositionSide    futures.PositionSideType
+	Type            futures.OrderType
+	TimeInForce     futures.TimeInForceType
+	Quantity        decimal.Decimal
+	Price           decimal.Decimal
+	StopPrice       decimal.Decimal
+	ActivationPrice decimal.Decimal
+	CallbackRate    float64 // percent, 0.1 - 5
+	IsReduceOnly    bool
+	IsClosePosition bool
+	WorkingType     futures.WorkingType
+	IsPriceProtect  bool
+	ClientOrderID   string
+}
+
+func NewOrderRequest(symbol futures.Symbol, side futures.SideType, orderType futures.OrderType) *OrderRequest {
+	return &OrderRequest{Symbol: symbol, Side: side, Type: orderType}
+}
+
+func (r *OrderRequest) WithPositionSide(positionSide futures.PositionSideType) *OrderRequest {
+	r.PositionSide = positionSide
+	return r
+}
+
+func (r *OrderRequest) WithTimeInForce(timeInForce futures.TimeInForceType) *OrderRequest {
+	r.TimeInForce = timeInForce
+	return r
+}
+
+// PostOnly makes limit order GTX, it is rejected instead of taking liquidity
+func (r *OrderRequest) PostOnly() *OrderRequest {
+	r.TimeInForce = futures.TimeInForceTypeGTX
+	return r
+}
+
+func (r *OrderRequest) WithQuantity(quantity decimal.Decimal) *OrderRequest {
+	r.Quantity = quantity
+	return r
+}
+
+func (r *OrderRequest) WithPrice(price decimal.Decimal) *OrderRequest {
+	r.Price = price
+	return r
+}
+
+func (r *OrderRequest) WithStopPrice(stopPrice decimal.Decimal) *OrderRequest {
+	r.StopPrice = stopPrice
+	return r
+}
+
+func (r *OrderRequest) WithActivationPrice(activationPrice decimal.Decimal) *OrderRequest {
+	r.ActivationPrice = activationPrice
+	return r
+}
+
+// WithQuantityFloat and WithPriceFloat are shortcuts for float inputs
+func (r *OrderRequest) WithQuantityFloat(quantity float64) *OrderRequest {
+	return r.WithQuantity(decimal.NewFromFloat(quantity))
+}
+
+func (r *OrderRequest) WithPriceFloat(price float64) *OrderRequest {
+	return r.WithPrice(decimal.NewFromFloat(price))
+}
+
+func (r *OrderRequest) WithCallbackRate(callbackRate float64) *OrderRequest {
+	r.CallbackRate = callbackRate
+	return r
+}
+
+func (r *OrderRequest) ReduceOnly() *OrderRequest {
+	r.IsReduceOnly = true
+	return r
+}
+
+func (r *OrderRequest) ClosePosition() *OrderRequest {
+	r.IsClosePosition = true
+	return r
+}
+
+func (r *OrderRequest) WithWorkingType(workingType futures.WorkingType) *OrderRequest {
+	r.WorkingType = workingType
+	return r
+}
+
+func (r *OrderRequest) PriceProtect() *OrderRequest {
+	r.IsPriceProtect = true
+	return r
+}
+
+func (r *OrderRequest) WithClientOrderID(clientOrderID string) *OrderRequest {
+	r.ClientOrderID = clientOrderID
+	return r
+}
+
+func (r *OrderRequest) Validate() error {
+	if r.Side != futures.SideTypeBuy && r.Side != futures.SideTypeSell {
+		return fmt.Errorf("%w: unknown side %q", ErrInvalidOrderRequest, r.Side)
+	}
+
+	var (
+		needPrice     bool
+		needStopPrice bool
+		needTIF       bool
+		canClose      bool
+	)
+	switch r.Type {
+	case futures.OrderTypeLimit:
+		needPrice, needTIF = true, true
+	case futures.OrderTypeMarket:
+	case futures.OrderTypeStop, futures.OrderTypeTakeProfit:
+		needPrice, needStopPrice = true, true
+	case futures.OrderTypeStopMarket, futures.OrderTypeTakeProfitMarket:
+		needStopPrice, canClose = true, true
+	case futures.OrderTypeTrailingStopMarket:
+		if r.CallbackRate < 0.1 || r.CallbackRate > 5 {
+			return fmt.Errorf("%w: callback rate %v is out of [0.1, 5]", ErrInvalidOrderRequest, r.CallbackRate)
+		}
+	default:
+		return fmt.Errorf("%w: unknown order type %q", ErrInvalidOrderRequest, r.Type)
+	}
+
+	if needPrice && !r.Price.IsPositive() {
+		return fmt.Errorf("%w: %s order requires price", ErrInvalidOrderRequest, r.Type)
+	}
+	if needStopPrice && !r.StopPrice.IsPositive() {
+		return fmt.Errorf("%w: %s order requires stop price", ErrInvalidOrderRequest, r.Type)
+	}
+	if needTIF && r.TimeInForce == "" {
+		return fmt.Errorf("%w: %s order requires time in force", ErrInvalidOrderRequest, r.Type)
+	}
+	if r.IsClosePosition {
+		if !canClose {
+			return fmt.Errorf("%w: closePosition is not supported by %s order", ErrInvalidOrderRequest, r.Type)
+		}
+		if r.IsReduceOnly || !r.Quantity.IsZero() {
+			return fmt.Errorf("%w: closePosition can't be used with quantity or reduceOnly", ErrInvalidOrderRequest)
+		}
+	} else if !r.Quantity.IsPositive() {
+		return fmt.Errorf("%w: %s order requires quantity", ErrInvalidOrderRequest, r.Type)
+	}
+	if r.IsReduceOnly && r.PositionSide != "" && r.PositionSide != futures.PositionSideTypeBoth {
+		return fmt.Errorf("%w: reduceOnly can't be used in hedge mode", ErrInvalidOrderRequest)
+	}
+	return nil
+}
+
+func (op *OrderProvider) createOrderService(r *OrderRequest) (*futures.CreateOrderService, error) {
+	service := op.client.NewCreateOrderService().
+		Symbol(r.Symbol.Symbol).
+		Side(r.Side).
+		Type(r.Type)
+
+	if r.PositionSide != "" {
+		service = service.PositionSide(r.PositionSide)
+	}
+	if r.TimeInForce != "" {
+		service = service.TimeInForce(r.TimeInForce)
+	}
+	if !r.Quantity.IsZero() {
+		service = service.Quantity(op.quantityToString(r.Quantity, r.Symbol.LotSizeFilter()))
+	}
+	if !r.Price.IsZero() {
+		service = service.Price(op.priceToString(r.Price, r.Symbol.PriceFilter()))
+	}
+	if !r.StopPrice.IsZero() {
+		service = service.StopPrice(op.priceToString(r.StopPrice, r.Symbol.PriceFilter()))
+	}
+	if !r.ActivationPrice.IsZero() {
+		service = service.ActivationPrice(op.priceToString(r.ActivationPrice, r.Symbol.PriceFilter()))
+	}
+	if r.CallbackRate != 0 {
+		service = service.CallbackRate(strconv.FormatFloat(r.CallbackRate, 'f', 1, 64))
+	}
+	if r.IsReduceOnly {
+		service = service.ReduceOnly(true)
+	}
+	if r.IsClosePosition {
+		service = service.ClosePosition(true)
+	}
+	if r.WorkingType != "" {
+		service = service.WorkingType(r.WorkingType)
+	}
+	if r.IsPriceProtect {
+		service = service.PriceProtect(true)
+	}
+	if r.ClientOrderID != "" {
+		service = service.NewClientOrderID(r.ClientOrderID)
+	}
+	return service, nil
+}
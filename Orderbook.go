@@ -3,14 +3,17 @@ package binance_modules
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
 
 	"github.com/adshao/go-binance/v2/futures"
 	"github.com/shopspring/decimal"
 	"golang.org/x/exp/slices"
 )
 
+type BookLevel struct {
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+}
+
 type OrderBook struct {
 	futures.DepthResponse
 
@@ -20,10 +23,12 @@ type OrderBook struct {
 	firstUpdateProcessed bool
 }
 
-func NewOrderBook(client *futures.Client, symbol string, limit int) (*OrderBook, error) {
+func NewOrderBook(ctx context.Context, client *futures.Client, symbol string, limit int) (*OrderBook, error) {
 	orderbook := new(OrderBook)
 
-	depth, err := client.NewDepthService().Symbol(symbol).Limit(limit).Do(context.Background())
+	depth, err := retry(ctx, DefaultRetryPolicy, func(ctx context.Context) (*futures.DepthResponse, error) {
+		return client.NewDepthService().Symbol(symbol).Limit(limit).Do(ctx)
+	})
 
 	if err != nil {
 		return orderbook, err
@@ -58,9 +63,7 @@ func (orderbook *OrderBook) Update(update *futures.WsDepthEvent) int {
 
 func (orderbook *OrderBook) updateAsks(update *futures.WsDepthEvent) {
 	var sortFunc = func(ask1, ask2 futures.Ask) bool {
-		price1, _ := strconv.ParseFloat(ask1.Price, 64)
-		price2, _ := strconv.ParseFloat(ask2.Price, 64)
-		return price1 < price2
+		return parseDecimal(ask1.Price).LessThan(parseDecimal(ask2.Price))
 	}
 	orderbook.newAsks = nil
 
@@ -70,9 +73,7 @@ func (orderbook *OrderBook) updateAsks(update *futures.WsDepthEvent) {
 			orderbook.newAsks = append(orderbook.newAsks, newAsk)
 			orderbook.Asks = append(orderbook.Asks, newAsk)
 		} else {
-			newQuantity, _ := decimal.NewFromString(newAsk.Quantity)
-			oldQuantity, _ := decimal.NewFromString(orderbook.Asks[index].Quantity)
-			diff := newQuantity.Add(oldQuantity.Neg()).String()
+			diff := parseDecimal(newAsk.Quantity).Sub(parseDecimal(orderbook.Asks[index].Quantity)).String()
 
 			orderbook.newAsks = append(orderbook.newAsks, futures.Ask{Price: newAsk.Price, Quantity: diff})
 			orderbook.Asks[index] = newAsk
@@ -85,9 +86,7 @@ func (orderbook *OrderBook) updateAsks(update *futures.WsDepthEvent) {
 
 func (orderbook *OrderBook) updateBids(update *futures.WsDepthEvent) {
 	var sortFunc = func(bid1, bid2 futures.Bid) bool {
-		price1, _ := strconv.ParseFloat(bid1.Price, 64)
-		price2, _ := strconv.ParseFloat(bid2.Price, 64)
-		return price1 > price2
+		return parseDecimal(bid1.Price).GreaterThan(parseDecimal(bid2.Price))
 	}
 	orderbook.newBids = nil
 
@@ -97,9 +96,7 @@ func (orderbook *OrderBook) updateBids(update *futures.WsDepthEvent) {
 			orderbook.newBids = append(orderbook.newBids, newBid)
 			orderbook.Bids = append(orderbook.Bids, newBid)
 		} else {
-			newQuantity, _ := decimal.NewFromString(newBid.Quantity)
-			oldQuantity, _ := decimal.NewFromString(orderbook.Bids[index].Quantity)
-			diff := newQuantity.Add(oldQuantity.Neg()).String()
+			diff := parseDecimal(newBid.Quantity).Sub(parseDecimal(orderbook.Bids[index].Quantity)).String()
 
 			orderbook.newBids = append(orderbook.newBids, futures.Bid{Price: newBid.Price, Quantity: diff})
 			orderbook.Bids[index] = newBid
@@ -107,7 +104,7 @@ func (orderbook *OrderBook) updateBids(update *futures.WsDepthEvent) {
 	}
 
 	slices.SortFunc(orderbook.Bids, sortFunc)
-	slices.SortFunc(orderbook.Bids, sortFunc)
+	slices.SortFunc(orderbook.newBids, sortFunc)
 }
 
 func (orderbook *OrderBook) removeZeroPriceLevels() {
@@ -116,10 +113,7 @@ func (orderbook *OrderBook) removeZeroPriceLevels() {
 		nonZeroBids []futures.Bid
 
 		isZero = func(s string) bool {
-			if len(s)-strings.Count(s, "0")-strings.Count(s, ".") > 0 {
-				return false
-			}
-			return true
+			return parseDecimal(s).IsZero()
 		}
 	)
 
@@ -148,6 +142,29 @@ func (orderbook *OrderBook) BestBid() futures.Bid {
 	return orderbook.Bids[0]
 }
 
+func (orderbook *OrderBook) BestAskLevel() BookLevel {
+	ask := orderbook.BestAsk()
+	return BookLevel{Price: parseDecimal(ask.Price), Quantity: parseDecimal(ask.Quantity)}
+}
+
+func (orderbook *OrderBook) BestBidLevel() BookLevel {
+	bid := orderbook.BestBid()
+	return BookLevel{Price: parseDecimal(bid.Price), Quantity: parseDecimal(bid.Quantity)}
+}
+
+func (orderbook *OrderBook) Spread() decimal.Decimal {
+	return orderbook.BestAskLevel().Price.Sub(orderbook.BestBidLevel().Price)
+}
+
+func (orderbook *OrderBook) MidPrice() decimal.Decimal {
+	return orderbook.BestAskLevel().Price.Add(orderbook.BestBidLevel().Price).Div(decimal.NewFromInt(2))
+}
+
+// MidPriceFloat is a shortcut for float consumers
+func (orderbook *OrderBook) MidPriceFloat() float64 {
+	return orderbook.MidPrice().InexactFloat64()
+}
+
 func (orderbook *OrderBook) Print(n int) {
 	var i int
 
@@ -0,0 +1,314 @@
+package binance_modules
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"golang.org/x/exp/slices"
+)
+
+const orderHistoryLimit = 1000
+
+var (
+	ErrStaleOrderUpdate       = errors.New("stale order update")
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+)
+
+func IsTerminalOrderStatus(status futures.OrderStatusType) bool {
+	switch status {
+	case futures.OrderStatusTypeFilled,
+		futures.OrderStatusTypeCanceled,
+		futures.OrderStatusTypeExpired,
+		futures.OrderStatusTypeRejected:
+		return true
+	}
+	return false
+}
+
+// PendingOrder is a request sent to exchange which is not acknowledged yet
+type PendingOrder struct {
+	ClientOrderID string
+	Request       *OrderRequest
+	Time          int64 // ms
+}
+
+// OrderRegistry keeps open orders indexed by ids and a bounded history of terminal ones.
+// Order status can only move NEW -> PARTIALLY_FILLED -> FILLED/CANCELED/EXPIRED/REJECTED.
+type OrderRegistry struct {
+	lock         sync.RWMutex
+	open         map[int64]*futures.Order
+	byClientID   map[string]*futures.Order
+	history      []*futures.Order
+	lastTradeIDs map[int64]int64
+	optimistic   map[int64]bool
+	pending      map[string]*PendingOrder
+	reserveLock  sync.Mutex // serializes checks of new orders with their registration as pending
+}
+
+func NewOrderRegistry() *OrderRegistry {
+	registry := new(OrderRegistry)
+	registry.open = make(map[int64]*futures.Order)
+	registry.byClientID = make(map[string]*futures.Order)
+	registry.lastTradeIDs = make(map[int64]int64)
+	registry.optimistic = make(map[int64]bool)
+	registry.pending = make(map[string]*PendingOrder)
+	return registry
+}
+
+// Load replaces open orders with a snapshot, history is kept
+func (r *OrderRegistry) Load(orders []*futures.Order) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.open = make(map[int64]*futures.Order)
+	r.byClientID = make(map[string]*futures.Order)
+	for _, order := range orders {
+		r.put(order)
+	}
+}
+
+// UpdateOptimistic applies expected result of a request, next update of the order is trusted.
+// It returns false when local state is newer.
+func (r *OrderRegistry) UpdateOptimistic(order *futures.Order) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	current := r.get(order.OrderID)
+	if current != nil && current.UpdateTime > order.UpdateTime {
+		return false
+	}
+	r.put(order)
+	r.optimistic[order.OrderID] = true
+	return true
+}
+
+// CancelAllOptimistic marks all open orders as canceled until user data events confirm it and returns them
+func (r *OrderRegistry) CancelAllOptimistic(updateTime int64) []*futures.Order {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var canceledOrders []*futures.Order
+	for _, order := range r.open {
+		canceled := *order
+		canceled.Status = futures.OrderStatusTypeCanceled
+		canceled.UpdateTime = updateTime
+		r.put(&canceled)
+		r.optimistic[order.OrderID] = true
+		canceledOrders = append(canceledOrders, &canceled)
+	}
+	return canceledOrders
+}
+
+func (r *OrderRegistry) Update(order *futures.Order, tradeID int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	current := r.get(order.OrderID)
+	if current == nil {
+		r.put(order)
+		r.setTradeID(order.OrderID, tradeID)
+		return nil
+	}
+
+	if r.optimistic[order.OrderID] {
+		// state was guessed by the request, exchange is the source of truth
+		delete(r.optimistic, order.OrderID)
+		r.put(order)
+		r.setTradeID(order.OrderID, tradeID)
+		return nil
+	}
+	if order.UpdateTime < current.UpdateTime {
+		return ErrStaleOrderUpdate
+	}
+	if tradeID != 0 && tradeID <= r.lastTradeIDs[order.OrderID] {
+		return ErrStaleOrderUpdate
+	}
+	if executedQuantity(order).LessThan(executedQuantity(current)) {
+		return ErrStaleOrderUpdate
+	}
+	if IsTerminalOrderStatus(current.Status) {
+		if current.Status == order.Status {
+			return ErrStaleOrderUpdate
+		}
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidOrderTransition, current.Status, order.Status)
+	}
+	if current.Status == futures.OrderStatusTypePartiallyFilled && order.Status == futures.OrderStatusTypeNew {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidOrderTransition, current.Status, order.Status)
+	}
+
+	r.put(order)
+	r.setTradeID(order.OrderID, tradeID)
+	return nil
+}
+
+// Refresh stores REST state of the order unless local state is newer, then it returns false
+func (r *OrderRegistry) Refresh(order *futures.Order) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	current := r.get(order.OrderID)
+	if current != nil && current.UpdateTime > order.UpdateTime {
+		return false
+	}
+	delete(r.optimistic, order.OrderID)
+	r.put(order)
+	return true
+}
+
+// replace stores REST state of the order without transition checks
+func (r *OrderRegistry) replace(order *futures.Order) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.optimistic, order.OrderID)
+	r.put(order)
+}
+
+func (r *OrderRegistry) put(order *futures.Order) {
+	delete(r.pending, order.ClientOrderID)
+	if IsTerminalOrderStatus(order.Status) {
+		delete(r.open, order.OrderID)
+		delete(r.byClientID, order.ClientOrderID)
+		r.archive(order)
+		return
+	}
+	r.open[order.OrderID] = order
+	r.byClientID[order.ClientOrderID] = order
+}
+
+func (r *OrderRegistry) archive(order *futures.Order) {
+	for i := range r.history {
+		if r.history[i].OrderID == order.OrderID {
+			r.history[i] = order
+			return
+		}
+	}
+	r.history = append(r.history, order)
+	if len(r.history) > orderHistoryLimit {
+		delete(r.lastTradeIDs, r.history[0].OrderID)
+		delete(r.optimistic, r.history[0].OrderID)
+		r.history = r.history[1:]
+	}
+}
+
+func (r *OrderRegistry) setTradeID(orderID, tradeID int64) {
+	if tradeID != 0 {
+		r.lastTradeIDs[orderID] = tradeID
+	}
+}
+
+func (r *OrderRegistry) Get(orderID int64) *futures.Order {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.get(orderID)
+}
+
+func (r *OrderRegistry) get(orderID int64) *futures.Order {
+	if order, ok := r.open[orderID]; ok {
+		return order
+	}
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].OrderID == orderID {
+			return r.history[i]
+		}
+	}
+	return nil
+}
+
+func (r *OrderRegistry) GetByClientID(clientOrderID string) *futures.Order {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if order, ok := r.byClientID[clientOrderID]; ok {
+		return order
+	}
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].ClientOrderID == clientOrderID {
+			return r.history[i]
+		}
+	}
+	return nil
+}
+
+// Open returns open orders sorted by creation
+func (r *OrderRegistry) Open() []*futures.Order {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	orders := make([]*futures.Order, 0, len(r.open))
+	for _, order := range r.open {
+		orders = append(orders, order)
+	}
+	slices.SortFunc(orders, func(order1, order2 *futures.Order) bool {
+		return order1.OrderID < order2.OrderID
+	})
+	return orders
+}
+
+// AddPending tracks the request by client order id until any state of the order is received
+func (r *OrderRegistry) AddPending(request *OrderRequest, t int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.pending[request.ClientOrderID] = &PendingOrder{ClientOrderID: request.ClientOrderID, Request: request, Time: t}
+}
+
+// Reserve registers the request as pending if check passes. Checks of other requests wait for it,
+// so each one sees requests reserved before it. check must not reserve orders of the registry.
+func (r *OrderRegistry) Reserve(request *OrderRequest, t int64, check func() error) error {
+	r.reserveLock.Lock()
+	defer r.reserveLock.Unlock()
+
+	err := check()
+	if err != nil {
+		return err
+	}
+	r.AddPending(request, t)
+	return nil
+}
+
+// RemovePending forgets the request which is known to be not placed
+func (r *OrderRegistry) RemovePending(clientOrderID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.pending, clientOrderID)
+}
+
+func (r *OrderRegistry) IsPending(clientOrderID string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	_, ok := r.pending[clientOrderID]
+	return ok
+}
+
+// Pending returns not acknowledged requests sorted by submit time
+func (r *OrderRegistry) Pending() []*PendingOrder {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	pending := make([]*PendingOrder, 0, len(r.pending))
+	for _, order := range r.pending {
+		pending = append(pending, order)
+	}
+	slices.SortFunc(pending, func(order1, order2 *PendingOrder) bool {
+		return order1.Time < order2.Time
+	})
+	return pending
+}
+
+func (r *OrderRegistry) History() []*futures.Order {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return append([]*futures.Order(nil), r.history...)
+}
+
+func executedQuantity(order *futures.Order) decimal.Decimal {
+	return parseDecimal(order.ExecutedQuantity)
+}
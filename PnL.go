@@ -0,0 +1,231 @@
+package binance_modules
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+// unrealized PnL snapshots from mark price are stored not more often than this
+const pnlSnapshotInterval = time.Minute
+
+type PnLEntry struct {
+	Time        int64 // ms
+	Symbol      string
+	Strategy    string
+	OrderID     int64
+	Realized    decimal.Decimal
+	Fee         decimal.Decimal
+	FeeAsset    string
+	MarginAsset string
+	Unrealized  decimal.Decimal // unrealized PnL of the symbol after the entry
+}
+
+type PnL struct {
+	Realized   decimal.Decimal
+	Unrealized decimal.Decimal
+	Fees       decimal.Decimal            // fees paid in margin asset
+	OtherFees  map[string]decimal.Decimal // fees paid in other assets, e.g. BNB
+	Net        decimal.Decimal
+}
+
+type EquityPoint struct {
+	Time   int64 // ms
+	Equity decimal.Decimal
+}
+
+// PnLFilter selects entries, summaries of a strategy have no unrealized PnL because
+// it belongs to the position of the symbol which strategies share
+type PnLFilter struct {
+	Symbol   string
+	Strategy string
+	From     time.Time
+	To       time.Time
+}
+
+func (f *PnLFilter) match(entry *PnLEntry) bool {
+	if f.Symbol != "" && f.Symbol != entry.Symbol {
+		return false
+	}
+	if f.Strategy != "" && f.Strategy != entry.Strategy {
+		return false
+	}
+	return true
+}
+
+func (f *PnLFilter) unrealized(entry *PnLEntry) decimal.Decimal {
+	if f.Strategy != "" {
+		return decimal.Zero
+	}
+	return entry.Unrealized
+}
+
+type PnLLedger struct {
+	lock          sync.RWMutex
+	entries       []PnLEntry
+	lastSnapshots map[string]int64
+}
+
+func NewPnLLedger() *PnLLedger {
+	ledger := new(PnLLedger)
+	ledger.lastSnapshots = make(map[string]int64)
+	return ledger
+}
+
+func (l *PnLLedger) Fill(update *futures.WsOrderTradeUpdate, strategy, marginAsset string, unrealized decimal.Decimal) {
+	if update.ExecutionType != futures.OrderExecutionTypeTrade {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.entries = append(l.entries, PnLEntry{
+		Time:        update.TradeTime,
+		Symbol:      update.Symbol,
+		Strategy:    strategy,
+		OrderID:     update.ID,
+		Realized:    parseDecimal(update.RealizedPnL),
+		Fee:         parseDecimal(update.Commission),
+		FeeAsset:    update.CommissionAsset,
+		MarginAsset: marginAsset,
+		Unrealized:  unrealized,
+	})
+	l.lastSnapshots[update.Symbol] = update.TradeTime
+}
+
+// Restore adds fills recorded by the journal before restart, so daily PnL and its loss limit survive
+// restarts. Unrealized PnL of that time is not recorded, it counts from zero.
+func (l *PnLLedger) Restore(fills []*Fill, marginAsset func(symbol string) string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, fill := range fills {
+		l.entries = append(l.entries, PnLEntry{
+			Time:        fill.Time,
+			Symbol:      fill.Symbol,
+			Strategy:    fill.Strategy,
+			OrderID:     fill.OrderID,
+			Realized:    parseDecimal(fill.RealizedPnL),
+			Fee:         parseDecimal(fill.Commission),
+			FeeAsset:    fill.CommissionAsset,
+			MarginAsset: marginAsset(fill.Symbol),
+		})
+	}
+	// summaries stop at the first entry after the period
+	sort.SliceStable(l.entries, func(i, j int) bool {
+		return l.entries[i].Time < l.entries[j].Time
+	})
+}
+
+// Mark stores unrealized PnL of the symbol, it has no strategy because strategies share the position
+func (l *PnLLedger) Mark(symbol string, unrealized decimal.Decimal, t int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if t-l.lastSnapshots[symbol] < pnlSnapshotInterval.Milliseconds() {
+		return
+	}
+	l.entries = append(l.entries, PnLEntry{
+		Time:       t,
+		Symbol:     symbol,
+		Unrealized: unrealized,
+	})
+	l.lastSnapshots[symbol] = t
+}
+
+func (l *PnLLedger) Summary(filter PnLFilter) PnL {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	var (
+		pnl        = PnL{OtherFees: make(map[string]decimal.Decimal)}
+		unrealized = make(map[string]decimal.Decimal)
+		start      = make(map[string]decimal.Decimal)
+		from       = filter.From.UnixMilli()
+	)
+
+	for i := range l.entries {
+		entry := &l.entries[i]
+		if !filter.match(entry) {
+			continue
+		}
+		if !filter.To.IsZero() && entry.Time >= filter.To.UnixMilli() {
+			break
+		}
+		if !filter.From.IsZero() && entry.Time < from {
+			start[entry.Symbol] = filter.unrealized(entry)
+			unrealized[entry.Symbol] = filter.unrealized(entry)
+			continue
+		}
+		pnl.Realized = pnl.Realized.Add(entry.Realized)
+		if entry.FeeAsset == entry.MarginAsset {
+			pnl.Fees = pnl.Fees.Add(entry.Fee)
+		} else if !entry.Fee.IsZero() {
+			pnl.OtherFees[entry.FeeAsset] = pnl.OtherFees[entry.FeeAsset].Add(entry.Fee)
+		}
+		unrealized[entry.Symbol] = filter.unrealized(entry)
+	}
+
+	for symbol := range unrealized {
+		pnl.Unrealized = pnl.Unrealized.Add(unrealized[symbol])
+		// only change of unrealized PnL within the period counts to net PnL
+		pnl.Net = pnl.Net.Add(unrealized[symbol].Sub(start[symbol]))
+	}
+	pnl.Net = pnl.Net.Add(pnl.Realized.Sub(pnl.Fees))
+	return pnl
+}
+
+func (l *PnLLedger) SymbolPnL(symbol string) PnL {
+	return l.Summary(PnLFilter{Symbol: symbol})
+}
+
+func (l *PnLLedger) StrategyPnL(strategy string) PnL {
+	return l.Summary(PnLFilter{Strategy: strategy})
+}
+
+// DailyPnL returns PnL of the whole account for UTC day containing t
+func (l *PnLLedger) DailyPnL(t time.Time) PnL {
+	from := t.UTC().Truncate(24 * time.Hour)
+	return l.Summary(PnLFilter{From: from, To: from.Add(24 * time.Hour)})
+}
+
+// EquityCurve returns cumulative net PnL after every entry matching the filter
+func (l *PnLLedger) EquityCurve(filter PnLFilter) []EquityPoint {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	var (
+		curve      []EquityPoint
+		closed     decimal.Decimal
+		unrealized = make(map[string]decimal.Decimal)
+	)
+
+	for i := range l.entries {
+		entry := &l.entries[i]
+		if !filter.match(entry) {
+			continue
+		}
+		if !filter.To.IsZero() && entry.Time >= filter.To.UnixMilli() {
+			break
+		}
+		closed = closed.Add(entry.Realized)
+		if entry.FeeAsset == entry.MarginAsset {
+			closed = closed.Sub(entry.Fee)
+		}
+		unrealized[entry.Symbol] = filter.unrealized(entry)
+
+		if !filter.From.IsZero() && entry.Time < filter.From.UnixMilli() {
+			continue
+		}
+		equity := closed
+		for symbol := range unrealized {
+			equity = equity.Add(unrealized[symbol])
+		}
+		curve = append(curve, EquityPoint{Time: entry.Time, Equity: equity})
+	}
+	return curve
+}
@@ -0,0 +1,414 @@
+package binance_modules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	rateLimitTypeWeight = "REQUEST_WEIGHT"
+	rateLimitTypeOrders = "ORDERS"
+)
+
+// exchange rejects signed requests older than recvWindow, part of it is left for the network
+const (
+	defaultRecvWindow = 5 * time.Second
+	recvWindowMargin  = time.Second
+)
+
+var ErrRateLimited = errors.New("request would exceed rate limit")
+
+// rateLimitWaitError is returned instead of holding a signed request longer than its recvWindow,
+// once and retry wait and sign the request again
+type rateLimitWaitError struct {
+	path string
+	wait time.Duration
+}
+
+func (e *rateLimitWaitError) Error() string {
+	return fmt.Sprintf("%s: %s, retry in %v", ErrRateLimited, e.path, e.wait)
+}
+
+func (e *rateLimitWaitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+type RateLimitUsage struct {
+	Type     string
+	Interval time.Duration
+	Used     int64
+	Limit    int64
+}
+
+func (u RateLimitUsage) Ratio() float64 {
+	if u.Limit == 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(u.Limit)
+}
+
+type rateWindow struct {
+	limitType string
+	interval  time.Duration
+	header    string
+	limit     int64
+	used      int64
+	start     time.Time
+}
+
+// roll starts a new window when the current one is over, windows are aligned to interval like on exchange
+func (w *rateWindow) roll(now time.Time) {
+	start := now.Truncate(w.interval)
+	if start.After(w.start) {
+		w.start = start
+		w.used = 0
+	}
+}
+
+// RateLimiter counts request weight and orders of REST calls before they are sent.
+// It is installed as transport of the client, so go-binance services and signed requests share it.
+// Signed requests are not held past their recvWindow, they are signed again after the wait.
+type RateLimiter struct {
+	lock        sync.Mutex
+	windows     []*rateWindow
+	bannedUntil time.Time
+	block       bool
+	log         *Logger
+}
+
+var rateLimiterLock = sync.Mutex{}
+var rateLimiterInstance *RateLimiter
+
+func GetRateLimiter(ctx context.Context, client *futures.Client) (*RateLimiter, error) {
+	if rateLimiterInstance == nil {
+		rateLimiterLock.Lock()
+		defer rateLimiterLock.Unlock()
+		if rateLimiterInstance == nil {
+			exInfo, err := GetExchangeInfo(ctx, client)
+			if err != nil {
+				return rateLimiterInstance, err
+			}
+			limiter, err := NewRateLimiter(exInfo.RateLimits)
+			if err != nil {
+				return rateLimiterInstance, err
+			}
+			rateLimiterInstance = limiter
+		}
+	}
+	return rateLimiterInstance, nil
+}
+
+// currentRateLimiter returns the limiter created by GetRateLimiter, nil before it is created
+func currentRateLimiter() *RateLimiter {
+	rateLimiterLock.Lock()
+	defer rateLimiterLock.Unlock()
+
+	return rateLimiterInstance
+}
+
+// NewRateLimiter creates a limiter which waits for the next window when a limit would be exceeded
+func NewRateLimiter(limits []futures.RateLimit) (*RateLimiter, error) {
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+	limiter := &RateLimiter{block: true, log: lg}
+	for _, limit := range limits {
+		if limit.RateLimitType != rateLimitTypeWeight && limit.RateLimitType != rateLimitTypeOrders {
+			continue
+		}
+		interval, suffix := rateLimitInterval(limit)
+		if interval == 0 {
+			continue
+		}
+		header := "X-MBX-USED-WEIGHT-" + suffix
+		if limit.RateLimitType == rateLimitTypeOrders {
+			header = "X-MBX-ORDER-COUNT-" + suffix
+		}
+		limiter.windows = append(limiter.windows, &rateWindow{
+			limitType: limit.RateLimitType,
+			interval:  interval,
+			header:    header,
+			limit:     limit.Limit,
+		})
+	}
+	return limiter, nil
+}
+
+// SetBlocking chooses between waiting for the next window and failing with ErrRateLimited
+func (rl *RateLimiter) SetBlocking(block bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	rl.block = block
+}
+
+// Install wraps transport of the client, it should be called before the client is copied by providers
+func (rl *RateLimiter) Install(client *futures.Client) {
+	if client.HTTPClient == nil || client.HTTPClient == http.DefaultClient {
+		client.HTTPClient = &http.Client{}
+	}
+	if _, ok := client.HTTPClient.Transport.(*rateLimitTransport); ok {
+		return
+	}
+	next := client.HTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.HTTPClient.Transport = &rateLimitTransport{limiter: rl, next: next}
+}
+
+func (rl *RateLimiter) Utilization() []RateLimitUsage {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := time.Now()
+	usage := make([]RateLimitUsage, 0, len(rl.windows))
+	for _, window := range rl.windows {
+		window.roll(now)
+		usage = append(usage, RateLimitUsage{
+			Type:     window.limitType,
+			Interval: window.interval,
+			Used:     window.used,
+			Limit:    window.limit,
+		})
+	}
+	return usage
+}
+
+// reserve counts the request or returns time to wait before it can be sent
+func (rl *RateLimiter) reserve(weight, orders int64) (time.Duration, bool) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := time.Now()
+	if now.Before(rl.bannedUntil) {
+		return rl.bannedUntil.Sub(now), rl.block
+	}
+
+	var wait time.Duration
+	for _, window := range rl.windows {
+		window.roll(now)
+		cost := window.cost(weight, orders)
+		if cost > 0 && window.used+cost > window.limit {
+			if delay := window.start.Add(window.interval).Sub(now); delay > wait {
+				wait = delay
+			}
+		}
+	}
+	if wait > 0 {
+		return wait, rl.block
+	}
+
+	for _, window := range rl.windows {
+		window.used += window.cost(weight, orders)
+	}
+	return 0, true
+}
+
+// observe takes used weight and order count reported by exchange
+func (rl *RateLimiter) observe(res *http.Response) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	now := time.Now()
+	for _, window := range rl.windows {
+		used, err := strconv.ParseInt(res.Header.Get(window.header), 10, 64)
+		if err != nil {
+			continue
+		}
+		window.roll(now)
+		window.used = used
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusTeapot {
+		retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After"))
+		if err != nil || retryAfter <= 0 {
+			retryAfter = 60
+		}
+		rl.bannedUntil = now.Add(time.Duration(retryAfter) * time.Second)
+		rl.log.WithFields(logrus.Fields{
+			"status":     res.StatusCode,
+			"retryAfter": retryAfter,
+		}).Warn("Rate limit exceeded, requests are paused")
+	}
+}
+
+func (w *rateWindow) cost(weight, orders int64) int64 {
+	if w.limitType == rateLimitTypeOrders {
+		return orders
+	}
+	return weight
+}
+
+type rateLimitTransport struct {
+	limiter *RateLimiter
+	next    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		start           = time.Now()
+		weight, orders  = requestCost(req)
+		maxWait, signed = signedMaxWait(req)
+	)
+	for {
+		wait, block := t.limiter.reserve(weight, orders)
+		if wait == 0 {
+			break
+		}
+		if !block {
+			return nil, fmt.Errorf("%w: %s %s, retry in %v", ErrRateLimited, req.Method, req.URL.Path, wait)
+		}
+		if signed && time.Since(start)+wait > maxWait {
+			return nil, &rateLimitWaitError{path: req.URL.Path, wait: wait}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	t.limiter.observe(res)
+	err = httpError(res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// httpError returns error responses without API error body as HTTPError, go-binance reports them as
+// API error code 0 which does not tell a 5xx of exchange from a 4xx of a proxy or firewall
+func httpError(res *http.Response) error {
+	if res.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	apiErr := new(common.APIError)
+	if json.Unmarshal(data, apiErr) == nil && apiErr.Code != 0 {
+		res.Body = io.NopCloser(bytes.NewReader(data))
+		return nil
+	}
+	return &HTTPError{StatusCode: res.StatusCode, Body: string(data)}
+}
+
+// signedMaxWait returns how long the signed request may wait before its timestamp is rejected
+func signedMaxWait(req *http.Request) (time.Duration, bool) {
+	query := req.URL.Query()
+	if query.Get("signature") == "" {
+		return 0, false
+	}
+	recvWindow := defaultRecvWindow
+	if ms, err := strconv.ParseInt(query.Get("recvWindow"), 10, 64); err == nil && ms > 0 {
+		recvWindow = time.Duration(ms) * time.Millisecond
+	}
+	return recvWindow - recvWindowMargin, true
+}
+
+// requestCost returns request weight and number of orders by the futures API documentation
+func requestCost(req *http.Request) (int64, int64) {
+	query := req.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	hasSymbol := query.Get("symbol") != ""
+
+	switch req.URL.Path {
+	case "/fapi/v1/depth":
+		switch {
+		case limit == 0 || limit > 500:
+			return 20, 0
+		case limit > 100:
+			return 10, 0
+		case limit > 50:
+			return 5, 0
+		}
+		return 2, 0
+	case "/fapi/v1/klines":
+		switch {
+		case limit > 1000:
+			return 10, 0
+		case limit >= 500:
+			return 5, 0
+		case limit >= 100 || limit == 0:
+			return 2, 0
+		}
+		return 1, 0
+	case "/fapi/v1/openOrders", "/fapi/v1/premiumIndex":
+		if hasSymbol {
+			return 1, 0
+		}
+		return 40, 0
+	case "/fapi/v1/leverageBracket":
+		if hasSymbol {
+			return 1, 0
+		}
+		return 20, 0
+	case "/fapi/v2/balance", "/fapi/v2/account", "/fapi/v2/positionRisk":
+		return 5, 0
+	case "/fapi/v1/positionSide/dual", "/fapi/v1/multiAssetsMargin":
+		return 30, 0
+	case "/fapi/v1/countdownCancelAll":
+		return 10, 0
+	case "/fapi/v1/commissionRate":
+		return 20, 0
+	case "/fapi/v1/order":
+		if req.Method == http.MethodPost || req.Method == http.MethodPut {
+			return 1, 1
+		}
+		return 1, 0
+	case "/fapi/v1/batchOrders":
+		if req.Method == http.MethodPost {
+			var orders []json.RawMessage
+			if json.Unmarshal([]byte(query.Get("batchOrders")), &orders) != nil {
+				return 5, 1
+			}
+			return 5, int64(len(orders))
+		}
+		return 1, 0
+	}
+	return 1, 0
+}
+
+func rateLimitInterval(limit futures.RateLimit) (time.Duration, string) {
+	var (
+		unit   time.Duration
+		letter string
+	)
+	switch strings.ToUpper(limit.Interval) {
+	case "SECOND":
+		unit, letter = time.Second, "S"
+	case "MINUTE":
+		unit, letter = time.Minute, "M"
+	case "HOUR":
+		unit, letter = time.Hour, "H"
+	case "DAY":
+		unit, letter = 24*time.Hour, "D"
+	default:
+		return 0, ""
+	}
+	return time.Duration(limit.IntervalNum) * unit, strconv.FormatInt(limit.IntervalNum, 10) + letter
+}
@@ -0,0 +1,313 @@
+package binance_modules
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReconcileInterval = 5 * time.Minute
+
+type DiscrepancyType string
+
+const (
+	DiscrepancyTypeBalance  DiscrepancyType = "BALANCE"
+	DiscrepancyTypePosition DiscrepancyType = "POSITION"
+	DiscrepancyTypeOrder    DiscrepancyType = "ORDER"
+)
+
+type Discrepancy struct {
+	Time   int64 // ms
+	Type   DiscrepancyType
+	Symbol string
+	Key    string // asset, position side or order id
+	Local  string
+	Remote string
+}
+
+type DiscrepancyHandler func(discrepancies []Discrepancy)
+
+type ReconcileMetrics struct {
+	Runs          int64
+	Failures      int64
+	Discrepancies int64
+	Skipped       int64 // balances and positions updated by the stream during the fetch, they are not compared
+	LastRun       time.Time
+	LastDuration  time.Duration
+	LastError     string
+}
+
+type reconciler struct {
+	run      sync.Mutex // serializes reconciliation runs
+	lock     sync.Mutex
+	cancel   context.CancelFunc
+	handlers []DiscrepancyHandler
+	metrics  ReconcileMetrics
+}
+
+// StartReconciler re-fetches account state from REST every interval and fixes local state
+func (a *Account) StartReconciler(interval time.Duration) {
+	a.StopReconciler()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.reconciler.lock.Lock()
+	a.reconciler.cancel = cancel
+	a.reconciler.lock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.reconcile(ctx)
+			}
+		}
+	}()
+}
+
+func (a *Account) StopReconciler() {
+	a.reconciler.lock.Lock()
+	defer a.reconciler.lock.Unlock()
+
+	if a.reconciler.cancel != nil {
+		a.reconciler.cancel()
+		a.reconciler.cancel = nil
+	}
+}
+
+func (a *Account) OnDiscrepancy(handler DiscrepancyHandler) {
+	a.reconciler.lock.Lock()
+	defer a.reconciler.lock.Unlock()
+
+	a.reconciler.handlers = append(a.reconciler.handlers, handler)
+}
+
+func (a *Account) ReconcileMetrics() ReconcileMetrics {
+	a.reconciler.lock.Lock()
+	defer a.reconciler.lock.Unlock()
+
+	return a.reconciler.metrics
+}
+
+func (a *Account) reconcile(ctx context.Context) {
+	discrepancies, err := a.Reconcile(ctx)
+	if err != nil {
+		a.log.WithFields(logrus.Fields{
+			"err": err.Error(),
+		}).Error("Failed to reconcile account")
+		return
+	}
+	for _, discrepancy := range discrepancies {
+		a.log.WithFields(logrus.Fields{
+			"type":   discrepancy.Type,
+			"symbol": discrepancy.Symbol,
+			"key":    discrepancy.Key,
+			"local":  discrepancy.Local,
+			"remote": discrepancy.Remote,
+		}).Warn("Account state discrepancy fixed")
+	}
+}
+
+// Reconcile compares local state with REST snapshot, fixes and returns differences
+func (a *Account) Reconcile(ctx context.Context) ([]Discrepancy, error) {
+	a.reconciler.run.Lock()
+	defer a.reconciler.run.Unlock()
+
+	start := time.Now()
+	snapshot, err := a.fetch(ctx)
+	if err != nil {
+		a.finishReconcile(start, nil, 0, err)
+		return nil, err
+	}
+
+	discrepancies, transitions, skipped := a.reconcileSnapshot(snapshot)
+	discrepancies = append(discrepancies, a.reconcileClosedOrders(ctx, snapshot)...)
+	if skipped > 0 {
+		a.log.WithFields(logrus.Fields{
+			"skipped": skipped,
+		}).Info("Balances and positions updated during reconciliation are not compared")
+	}
+	a.finishReconcile(start, discrepancies, skipped, nil)
+	a.notifyStates(transitions)
+	return discrepancies, nil
+}
+
+func (a *Account) finishReconcile(start time.Time, discrepancies []Discrepancy, skipped int, err error) {
+	a.reconciler.lock.Lock()
+	metrics := &a.reconciler.metrics
+	metrics.Runs++
+	metrics.LastRun = start
+	metrics.LastDuration = time.Since(start)
+	metrics.LastError = ""
+	if err != nil {
+		metrics.Failures++
+		metrics.LastError = err.Error()
+	}
+	metrics.Discrepancies += int64(len(discrepancies))
+	metrics.Skipped += int64(skipped)
+	handlers := a.reconciler.handlers
+	a.reconciler.lock.Unlock()
+
+	if len(discrepancies) > 0 {
+		for _, handler := range handlers {
+			handler(discrepancies)
+		}
+	}
+}
+
+// reconcileSnapshot fixes local state by the snapshot, returns discrepancies, position transitions
+// and number of balances and positions skipped because the stream updated them during the fetch
+func (a *Account) reconcileSnapshot(snapshot *accountSnapshot) ([]Discrepancy, []PositionTransition, int) {
+	var (
+		discrepancies []Discrepancy
+		transitions   []PositionTransition
+		skipped       int
+		now           = time.Now().UnixMilli()
+	)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.DualSide = snapshot.dualSide
+	a.MultiAssets = snapshot.multiAssets
+	a.margin = snapshot.margin
+	a.setRiskData(snapshot)
+
+	// stream events received during fetch are newer than the snapshot, both times are local
+	for _, balance := range snapshot.balances {
+		if a.balanceTimes[balance.Asset] >= snapshot.time {
+			skipped++
+			continue
+		}
+		local, ok := a.Balances[balance.Asset]
+		if !ok || !sameNumber(local.Balance, balance.Balance) || !sameNumber(local.CrossWalletBalance, balance.CrossWalletBalance) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Time:   now,
+				Type:   DiscrepancyTypeBalance,
+				Key:    balance.Asset,
+				Local:  balanceString(local),
+				Remote: balanceString(balance),
+			})
+		}
+		a.Balances[balance.Asset] = balance
+	}
+
+	for _, position := range snapshot.positions {
+		side := futures.PositionSideType(position.PositionSide)
+		if a.positionTimes[positionKey(position.Symbol, side)] >= snapshot.time {
+			skipped++
+			continue
+		}
+		status := a.status(position.Symbol)
+		local := status.Position(side)
+		if !sameNumber(local.PositionAmt, position.PositionAmt) || !sameNumber(local.EntryPrice, position.EntryPrice) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Time:   now,
+				Type:   DiscrepancyTypePosition,
+				Symbol: position.Symbol,
+				Key:    string(side),
+				Local:  local.PositionAmt + "@" + local.EntryPrice,
+				Remote: position.PositionAmt + "@" + position.EntryPrice,
+			})
+		}
+		status.setPosition(position)
+	}
+
+	for _, order := range snapshot.orders {
+		status := a.status(order.Symbol)
+		local := status.Orders.Get(order.OrderID)
+		if local != nil && local.UpdateTime > order.UpdateTime {
+			continue
+		}
+		if local == nil || local.Status != order.Status || !sameNumber(local.ExecutedQuantity, order.ExecutedQuantity) {
+			discrepancies = append(discrepancies, orderDiscrepancy(now, local, order))
+			status.Orders.replace(order)
+		}
+	}
+
+	for _, status := range a.statuses {
+		status.setMode(a.DualSide, a.MultiAssets)
+		status.setCollateral(a.balances())
+		status.setMargin(a.margin)
+		transitions = append(transitions, status.UpdateStates(now)...)
+	}
+	return discrepancies, transitions, skipped
+}
+
+// reconcileClosedOrders queries final state of orders which are open locally but not on exchange
+func (a *Account) reconcileClosedOrders(ctx context.Context, snapshot *accountSnapshot) []Discrepancy {
+	var (
+		discrepancies []Discrepancy
+		missing       []*futures.Order
+		remote        = make(map[int64]bool)
+	)
+	for _, order := range snapshot.orders {
+		remote[order.OrderID] = true
+	}
+
+	a.lock.RLock()
+	for _, status := range a.statuses {
+		for _, order := range status.Orders.Open() {
+			if !remote[order.OrderID] && order.UpdateTime < snapshot.time {
+				missing = append(missing, order)
+			}
+		}
+	}
+	a.lock.RUnlock()
+
+	for _, local := range missing {
+		order, err := retry(ctx, a.retryPolicy(), func(ctx context.Context) (*futures.Order, error) {
+			return a.client.NewGetOrderService().Symbol(local.Symbol).OrderID(local.OrderID).Do(ctx)
+		})
+		if err != nil {
+			a.log.WithFields(logrus.Fields{
+				"symbol":  local.Symbol,
+				"orderID": local.OrderID,
+				"err":     err.Error(),
+			}).Error("Failed to query order")
+			continue
+		}
+
+		a.lock.Lock()
+		status := a.status(order.Symbol)
+		current := status.Orders.Get(order.OrderID)
+		if current != nil && current.UpdateTime <= order.UpdateTime && current.Status != order.Status {
+			discrepancies = append(discrepancies, orderDiscrepancy(time.Now().UnixMilli(), current, order))
+			status.Orders.replace(order)
+		}
+		a.lock.Unlock()
+	}
+	return discrepancies
+}
+
+func orderDiscrepancy(t int64, local, remote *futures.Order) Discrepancy {
+	discrepancy := Discrepancy{
+		Time:   t,
+		Type:   DiscrepancyTypeOrder,
+		Symbol: remote.Symbol,
+		Key:    strconv.FormatInt(remote.OrderID, 10),
+		Remote: string(remote.Status) + " " + remote.ExecutedQuantity + "/" + remote.OrigQuantity,
+	}
+	if local != nil {
+		discrepancy.Local = string(local.Status) + " " + local.ExecutedQuantity + "/" + local.OrigQuantity
+	}
+	return discrepancy
+}
+
+func balanceString(balance *futures.Balance) string {
+	if balance == nil {
+		return ""
+	}
+	return balance.Balance + " (cross " + balance.CrossWalletBalance + ")"
+}
+
+func sameNumber(a, b string) bool {
+	return parseDecimal(a).Equal(parseDecimal(b))
+}
@@ -0,0 +1,56 @@
+package binance_modules
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// signedRequest calls endpoints which are not covered by go-binance services
+func signedRequest(ctx context.Context, client *futures.Client, method, endpoint string, params url.Values) ([]byte, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli()-client.TimeOffset, 10))
+
+	query := params.Encode()
+	mac := hmac.New(sha256.New, []byte(client.SecretKey))
+	mac.Write([]byte(query))
+	query = query + "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, method, client.BaseURL+endpoint+"?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-MBX-APIKEY", client.APIKey)
+
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		apiErr := new(common.APIError)
+		err = json.Unmarshal(data, apiErr)
+		if err != nil || apiErr.Code == 0 {
+			return nil, &HTTPError{StatusCode: res.StatusCode, Body: string(data)}
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
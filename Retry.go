@@ -0,0 +1,161 @@
+package binance_modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+const errCodeTimestampOutsideRecvWindow = -1021
+
+// RetryPolicy limits REST calls, only idempotent requests are retried
+type RetryPolicy struct {
+	Attempts  int           // total attempts, 1 disables retries
+	BaseDelay time.Duration // backoff before the second attempt
+	MaxDelay  time.Duration
+	Timeout   time.Duration // per attempt, 0 means no timeout besides the caller context
+	client    *futures.Client
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  3,
+	BaseDelay: 200 * time.Millisecond,
+	MaxDelay:  5 * time.Second,
+	Timeout:   10 * time.Second,
+}
+
+// HTTPError is returned by signed requests when the response body is not an API error
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRetryable reports errors which may succeed on the next attempt:
+// network failures, timeouts on exchange side, timestamp drift and 5xx responses
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) {
+		// code 0 is an error response without API error body, it can be a 4xx of a proxy or firewall,
+		// the rate limiter transport returns such responses as HTTPError with the status
+		switch apiErr.Code {
+		case -1000, // unknown error
+			-1001, // disconnected
+			-1006, // unexpected response
+			-1007, // timeout waiting for backend
+			-1021: // timestamp outside of recvWindow
+			return true
+		}
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// withTimeSync returns the policy which resyncs time offset of the client before retrying timestamp errors
+func (p RetryPolicy) withTimeSync(client *futures.Client) RetryPolicy {
+	p.client = client
+	return p
+}
+
+// syncTime updates time offset of the client when exchange rejected the request timestamp,
+// a failed sync makes the next attempt fail the same way
+func (p RetryPolicy) syncTime(ctx context.Context, err error) {
+	if p.client != nil && hasErrorCode(err, errCodeTimestampOutsideRecvWindow) {
+		p.client.NewSetServerTimeService().Do(ctx)
+	}
+}
+
+// backoff is exponential with full jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+func (p RetryPolicy) attempt(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.Timeout > 0 {
+		return context.WithTimeout(ctx, p.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// retry calls idempotent request until it succeeds, fails with fatal error or ctx is done
+func retry[T any](ctx context.Context, policy RetryPolicy, call func(ctx context.Context) (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for i := 0; i < policy.Attempts || i == 0; i++ {
+		if i > 0 {
+			timer := time.NewTimer(policy.backoff(i - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return result, err
+			case <-timer.C:
+			}
+		}
+
+		result, err = attempt(ctx, policy, call)
+		if err == nil || !IsRetryable(err) || ctx.Err() != nil {
+			return result, err
+		}
+		policy.syncTime(ctx, err)
+	}
+	return result, err
+}
+
+// once calls request which must not be repeated blindly, only timeout of the policy is applied
+func once[T any](ctx context.Context, policy RetryPolicy, call func(ctx context.Context) (T, error)) (T, error) {
+	return attempt(ctx, policy, call)
+}
+
+// attempt calls the request once. A signed request held back by the rate limiter was not sent,
+// it is signed again by the next call after the wait, so its timestamp is not outside recvWindow.
+func attempt[T any](ctx context.Context, policy RetryPolicy, call func(ctx context.Context) (T, error)) (T, error) {
+	for {
+		attemptCtx, cancel := policy.attempt(ctx)
+		result, err := call(attemptCtx)
+		cancel()
+
+		var waitErr *rateLimitWaitError
+		if !errors.As(err, &waitErr) {
+			return result, err
+		}
+		timer := time.NewTimer(waitErr.wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package binance_modules
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+type Risk struct {
+	Side                futures.PositionSideType
+	Notional            decimal.Decimal
+	Leverage            int
+	LiquidationPrice    decimal.Decimal
+	MaintenanceMargin   decimal.Decimal
+	MarginBalance       decimal.Decimal
+	PositionMarginRatio decimal.Decimal // maintenance margin of this position / margin balance, cross positions share it, see Account.CrossMarginRatio
+	Bracket             *futures.Bracket
+	FundingRate         decimal.Decimal
+	NextFundingTime     int64           // ms
+	NextFunding         decimal.Decimal // estimated funding payment, negative when the position pays
+}
+
+func (s *Status) Risk(side futures.PositionSideType) Risk {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	position := s.position(side)
+
+	amount := parseDecimal(position.PositionAmt)
+	markPrice := parseDecimal(position.MarkPrice)
+	leverage, _ := strconv.Atoi(position.Leverage)
+	fundingRate := parseDecimal(s.FundingRate)
+
+	risk := Risk{
+		Side:             futures.PositionSideType(position.PositionSide),
+		Notional:         amount.Mul(markPrice).Abs(),
+		Leverage:         leverage,
+		LiquidationPrice: parseDecimal(position.LiquidationPrice),
+		FundingRate:      fundingRate,
+		NextFundingTime:  s.NextFundingTime,
+		NextFunding:      amount.Mul(markPrice).Mul(fundingRate).Neg(),
+	}
+
+	risk.Bracket = s.bracket(risk.Notional)
+	if risk.Bracket != nil {
+		risk.MaintenanceMargin = risk.Notional.Mul(decimal.NewFromFloat(risk.Bracket.MaintMarginRatio)).Sub(decimal.NewFromFloat(risk.Bracket.Cum))
+	}
+
+	if isIsolated(position) {
+		risk.MarginBalance = parseDecimal(position.IsolatedWallet).Add(parseDecimal(position.UnRealizedProfit))
+	} else {
+		risk.MarginBalance = parseDecimal(s.TotalMargin)
+	}
+	if risk.MarginBalance.IsPositive() {
+		risk.PositionMarginRatio = risk.MaintenanceMargin.Div(risk.MarginBalance)
+	}
+	return risk
+}
+
+// CrossMarginRatio returns maintenance margin of all cross positions sharing margin with the symbol
+// divided by the cross margin balance, the positions are liquidated at 1
+func (a *Account) CrossMarginRatio(symbol string) decimal.Decimal {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	status, ok := a.statuses[symbol]
+	if !ok {
+		return decimal.Zero
+	}
+	marginBalance, _ := status.Margin()
+	if !marginBalance.IsPositive() {
+		return decimal.Zero
+	}
+
+	maintenance := decimal.Zero
+	for _, other := range a.statuses {
+		if !a.MultiAssets && other.MarginAsset() != status.MarginAsset() {
+			continue
+		}
+		for _, side := range other.Sides() {
+			if isIsolated(other.Position(side)) {
+				continue
+			}
+			maintenance = maintenance.Add(other.Risk(side).MaintenanceMargin)
+		}
+	}
+	return maintenance.Div(marginBalance)
+}
+
+// bracket returns the leverage bracket of the notional. Caller must hold the lock.
+func (s *Status) bracket(notional decimal.Decimal) *futures.Bracket {
+	for i := range s.Brackets {
+		if notional.GreaterThanOrEqual(decimal.NewFromFloat(s.Brackets[i].NotionalFloor)) && notional.LessThan(decimal.NewFromFloat(s.Brackets[i].NotionalCap)) {
+			return &s.Brackets[i]
+		}
+	}
+	if len(s.Brackets) > 0 && notional.IsPositive() {
+		return &s.Brackets[len(s.Brackets)-1]
+	}
+	return nil
+}
+
+// updateRisk recalculates fields which are not sent by streams.
+// Liquidation price is estimated for the single position, REST value replaces it on reconciliation.
+// Caller must hold the lock.
+func (s *Status) updateRisk(side futures.PositionSideType) {
+	position, ok := s.positions[side]
+	if !ok {
+		return
+	}
+
+	amount := parseDecimal(position.PositionAmt)
+	markPrice := parseDecimal(position.MarkPrice)
+	entryPrice := parseDecimal(position.EntryPrice)
+	position.Notional = amount.Mul(markPrice).String()
+
+	bracket := s.bracket(amount.Mul(markPrice).Abs())
+	if amount.IsZero() || bracket == nil {
+		position.LiquidationPrice = "0"
+		return
+	}
+
+	var walletBalance decimal.Decimal
+	if isIsolated(position) {
+		walletBalance = parseDecimal(position.IsolatedWallet)
+	} else {
+		walletBalance = parseDecimal(s.Balance.CrossWalletBalance)
+	}
+
+	direction := decimal.NewFromInt(1)
+	if amount.IsNegative() {
+		direction = direction.Neg()
+	}
+	quantity := amount.Abs()
+	cum := decimal.NewFromFloat(bracket.Cum)
+	maintMarginRatio := decimal.NewFromFloat(bracket.MaintMarginRatio)
+	liquidationPrice := walletBalance.Add(cum).Sub(direction.Mul(quantity).Mul(entryPrice)).
+		Div(quantity.Mul(maintMarginRatio).Sub(direction.Mul(quantity)))
+	if liquidationPrice.IsNegative() {
+		liquidationPrice = decimal.Zero
+	}
+	position.LiquidationPrice = liquidationPrice.String()
+}
+
+func (s *Status) ConfigUpdate(update *futures.WsAccountConfigUpdate) {
+	if update.Symbol != s.symbol {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for side, position := range s.positions {
+		updated := *position
+		updated.Leverage = strconv.FormatInt(update.Leverage, 10)
+		s.positions[side] = &updated
+	}
+}
+
+func isIsolated(position *futures.PositionRisk) bool {
+	return strings.EqualFold(position.MarginType, string(futures.MarginTypeIsolated))
+}
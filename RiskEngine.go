@@ -0,0 +1,355 @@
+package binance_modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrRiskLimit  = errors.New("order rejected by risk limit")
+	ErrKillSwitch = errors.New("trading is halted by kill switch")
+)
+
+// RiskLimitError describes an order rejected by the risk engine, it wraps ErrRiskLimit
+type RiskLimitError struct {
+	Limit string
+	Value decimal.Decimal
+	Max   decimal.Decimal
+}
+
+func (e *RiskLimitError) Error() string {
+	return fmt.Sprintf("%s: %s exceeds %s", e.Limit, e.Value, e.Max)
+}
+
+func (e *RiskLimitError) Unwrap() error {
+	return ErrRiskLimit
+}
+
+// RiskLimits are checked before orders are sent, zero values disable limits
+type RiskLimits struct {
+	MaxPosition        decimal.Decimal // position quantity of a symbol with open orders of the same direction
+	MaxSymbolNotional  decimal.Decimal // the same position valued at the order price
+	MaxAccountNotional decimal.Decimal // positions of all tracked symbols
+	MaxOpenOrders      int             // open orders of all tracked symbols
+	MaxOrdersPerMinute int
+	MaxDailyLoss       decimal.Decimal // positive amount of net PnL loss within the UTC day
+	PriceBand          decimal.Decimal // max deviation of order price from mid or mark price, e.g. 0.05
+}
+
+type riskSymbol struct {
+	symbol    futures.Symbol
+	status    *Status
+	orderbook *OrderBook
+}
+
+// RiskEngine checks every order of the providers it is set to, orders of symbols it does not track
+// are rejected. Orders reducing a position are only checked by the price band, so positions can be
+// closed when limits are reached or the kill switch is triggered.
+type RiskEngine struct {
+	lock    sync.Mutex
+	limits  RiskLimits
+	symbols map[string]*riskSymbol
+	pnl     *PnLLedger
+	sent    []time.Time
+	killed  bool
+	reason  string
+	log     *Logger
+}
+
+// NewRiskEngine creates the engine, pnl may be nil when daily loss is not limited.
+// PnL of the account is restored from the fills journal, so the limit holds after restarts.
+func NewRiskEngine(limits RiskLimits, pnl *PnLLedger) (*RiskEngine, error) {
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+	engine := &RiskEngine{
+		limits:  limits,
+		symbols: make(map[string]*riskSymbol),
+		pnl:     pnl,
+		log:     lg,
+	}
+	return engine, nil
+}
+
+// Track adds the symbol to checks, orderbook may be nil, then price band uses mark price
+func (re *RiskEngine) Track(symbol futures.Symbol, status *Status, orderbook *OrderBook) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	re.symbols[symbol.Symbol] = &riskSymbol{symbol: symbol, status: status, orderbook: orderbook}
+}
+
+func (re *RiskEngine) SetLimits(limits RiskLimits) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	re.limits = limits
+}
+
+// Killed reports whether the kill switch is triggered and why
+func (re *RiskEngine) Killed() (bool, string) {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	return re.killed, re.reason
+}
+
+// Kill blocks new orders, cancels open orders and closes positions of tracked symbols with market orders
+func (re *RiskEngine) Kill(ctx context.Context, provider *OrderProvider, reason string) error {
+	re.lock.Lock()
+	re.killed = true
+	re.reason = reason
+	symbols := make([]*riskSymbol, 0, len(re.symbols))
+	for _, symbol := range re.symbols {
+		symbols = append(symbols, symbol)
+	}
+	re.lock.Unlock()
+
+	re.log.WithFields(logrus.Fields{
+		"reason":  reason,
+		"symbols": len(symbols),
+	}).Error("Kill switch triggered")
+
+	var firstErr error
+	for _, symbol := range symbols {
+		err := provider.CancelAllOrders(ctx, symbol.symbol)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for _, side := range symbol.status.Sides() {
+			amount := symbol.status.PositionAmount(side)
+			if amount.IsZero() {
+				continue
+			}
+			orderSide := futures.SideTypeSell
+			if amount.IsNegative() {
+				orderSide = futures.SideTypeBuy
+			}
+			request := NewOrderRequest(symbol.symbol, orderSide, futures.OrderTypeMarket).
+				WithPositionSide(side).
+				WithQuantity(amount.Abs())
+			if side == futures.PositionSideTypeBoth {
+				request.ReduceOnly()
+			}
+			_, err = provider.Submit(ctx, request)
+			if err != nil {
+				re.log.WithFields(logrus.Fields{
+					"symbol":       symbol.symbol.Symbol,
+					"positionSide": side,
+					"quantity":     amount.String(),
+					"err":          err.Error(),
+				}).Error("Failed to close position by kill switch")
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	return firstErr
+}
+
+// Reset allows new orders after the kill switch
+func (re *RiskEngine) Reset() {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	re.killed = false
+	re.reason = ""
+	re.log.Info("Kill switch reset")
+}
+
+// Check passes the order or returns ErrKillSwitch or RiskLimitError, pending is number of orders
+// sent before or together with it which are not open in status yet. Passed orders count to the orders per minute.
+func (re *RiskEngine) Check(r *OrderRequest, pending int) error {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	tracked := re.symbols[r.Symbol.Symbol]
+	if tracked == nil {
+		return fmt.Errorf("%w: symbol %s is not tracked", ErrRiskLimit, r.Symbol.Symbol)
+	}
+	price := r.Price
+	if price.IsZero() {
+		price = re.referencePrice(tracked)
+	}
+
+	err := re.checkPriceBand(r, tracked)
+	if err != nil {
+		return err
+	}
+	if reducesPosition(r, tracked.status) {
+		return nil
+	}
+
+	if re.killed {
+		return fmt.Errorf("%w: %s", ErrKillSwitch, re.reason)
+	}
+
+	now := time.Now()
+	sent := re.sent[:0]
+	for _, t := range re.sent {
+		if now.Sub(t) < time.Minute {
+			sent = append(sent, t)
+		}
+	}
+	re.sent = sent
+	if re.limits.MaxOrdersPerMinute > 0 && len(re.sent) >= re.limits.MaxOrdersPerMinute {
+		return &RiskLimitError{Limit: "orders per minute", Value: decimal.NewFromInt(int64(len(re.sent) + 1)), Max: decimal.NewFromInt(int64(re.limits.MaxOrdersPerMinute))}
+	}
+
+	if re.limits.MaxOpenOrders > 0 {
+		open := pending
+		for _, symbol := range re.symbols {
+			open += len(symbol.status.Orders.Open())
+		}
+		if open >= re.limits.MaxOpenOrders {
+			return &RiskLimitError{Limit: "open orders", Value: decimal.NewFromInt(int64(open + 1)), Max: decimal.NewFromInt(int64(re.limits.MaxOpenOrders))}
+		}
+	}
+
+	if re.limits.MaxDailyLoss.IsPositive() && re.pnl != nil {
+		loss := re.pnl.DailyPnL(now).Net.Neg()
+		if loss.GreaterThanOrEqual(re.limits.MaxDailyLoss) {
+			return &RiskLimitError{Limit: "daily loss", Value: loss, Max: re.limits.MaxDailyLoss}
+		}
+	}
+
+	position := worstPosition(r, tracked.status)
+	if re.limits.MaxPosition.IsPositive() && position.GreaterThan(re.limits.MaxPosition) {
+		return &RiskLimitError{Limit: "position", Value: position, Max: re.limits.MaxPosition}
+	}
+	notional := position.Mul(price)
+	if re.limits.MaxSymbolNotional.IsPositive() && notional.GreaterThan(re.limits.MaxSymbolNotional) {
+		return &RiskLimitError{Limit: "symbol notional", Value: notional, Max: re.limits.MaxSymbolNotional}
+	}
+	if re.limits.MaxAccountNotional.IsPositive() {
+		total := r.Quantity.Mul(price)
+		for _, symbol := range re.symbols {
+			markPrice := symbol.status.MarkPrice()
+			for _, side := range symbol.status.Sides() {
+				total = total.Add(symbol.status.PositionAmount(side).Abs().Mul(markPrice))
+			}
+		}
+		if total.GreaterThan(re.limits.MaxAccountNotional) {
+			return &RiskLimitError{Limit: "account notional", Value: total, Max: re.limits.MaxAccountNotional}
+		}
+	}
+
+	re.sent = append(re.sent, now)
+	return nil
+}
+
+// checkModify applies the price band and the kill switch to a modification of an open order,
+// the request carries client order id of the order so it is not counted twice
+func (re *RiskEngine) checkModify(r *OrderRequest) error {
+	re.lock.Lock()
+	defer re.lock.Unlock()
+
+	tracked := re.symbols[r.Symbol.Symbol]
+	if tracked == nil {
+		return fmt.Errorf("%w: symbol %s is not tracked", ErrRiskLimit, r.Symbol.Symbol)
+	}
+	err := re.checkPriceBand(r, tracked)
+	if err != nil {
+		return err
+	}
+	if re.killed && !reducesPosition(r, tracked.status) {
+		return fmt.Errorf("%w: %s", ErrKillSwitch, re.reason)
+	}
+	return nil
+}
+
+func (re *RiskEngine) checkPriceBand(r *OrderRequest, tracked *riskSymbol) error {
+	if re.limits.PriceBand.IsZero() || !r.Price.IsPositive() {
+		return nil
+	}
+	reference := re.referencePrice(tracked)
+	if !reference.IsPositive() {
+		return nil
+	}
+	deviation := r.Price.Sub(reference).Abs().Div(reference)
+	if deviation.GreaterThan(re.limits.PriceBand) {
+		return &RiskLimitError{Limit: "price band", Value: deviation, Max: re.limits.PriceBand}
+	}
+	return nil
+}
+
+// referencePrice is the mid price of the book if it is tracked, mark price otherwise
+func (re *RiskEngine) referencePrice(symbol *riskSymbol) decimal.Decimal {
+	if symbol.orderbook != nil && len(symbol.orderbook.Bids) > 0 && len(symbol.orderbook.Asks) > 0 {
+		return symbol.orderbook.MidPrice()
+	}
+	return symbol.status.MarkPrice()
+}
+
+// reducesPosition reports orders which can only decrease the position of their side: reduce-only
+// and close-position orders, or opposite orders which fit the part of the position not already
+// covered by other open and pending orders of the same direction
+func reducesPosition(r *OrderRequest, status *Status) bool {
+	if r.IsReduceOnly || r.IsClosePosition {
+		return true
+	}
+	amount := status.PositionAmount(r.PositionSide)
+	if amount.IsZero() || (r.Side == futures.SideTypeSell) != amount.IsPositive() {
+		return false
+	}
+
+	positionSide := positionSideOf(r.PositionSide)
+	uncovered := amount.Abs()
+	for _, order := range status.Orders.Open() {
+		if order.ClientOrderID == r.ClientOrderID || order.Side != r.Side || order.PositionSide != positionSide {
+			continue
+		}
+		if order.ClosePosition {
+			return false
+		}
+		uncovered = uncovered.Sub(parseDecimal(order.OrigQuantity).Sub(parseDecimal(order.ExecutedQuantity)))
+	}
+	for _, pending := range status.Orders.Pending() {
+		request := pending.Request
+		if pending.ClientOrderID == r.ClientOrderID || request.Side != r.Side || positionSideOf(request.PositionSide) != positionSide {
+			continue
+		}
+		if request.IsClosePosition {
+			return false
+		}
+		uncovered = uncovered.Sub(request.Quantity)
+	}
+	return r.Quantity.LessThanOrEqual(uncovered)
+}
+
+// positionSideOf returns BOTH for requests without position side as exchange reports their orders
+func positionSideOf(positionSide futures.PositionSideType) futures.PositionSideType {
+	if positionSide == "" {
+		return futures.PositionSideTypeBoth
+	}
+	return positionSide
+}
+
+// worstPosition returns position size after the order and all open orders of the same direction are filled
+func worstPosition(r *OrderRequest, status *Status) decimal.Decimal {
+	position := status.PositionAmount(r.PositionSide)
+	direction := decimal.NewFromInt(1)
+	if r.Side == futures.SideTypeSell {
+		direction = direction.Neg()
+	}
+	position = position.Add(r.Quantity.Mul(direction))
+
+	positionSide := positionSideOf(r.PositionSide)
+	for _, order := range status.Orders.Open() {
+		if order.Side != r.Side || order.PositionSide != positionSide || order.ReduceOnly || order.ClosePosition {
+			continue
+		}
+		remaining := parseDecimal(order.OrigQuantity).Sub(parseDecimal(order.ExecutedQuantity))
+		position = position.Add(remaining.Mul(direction))
+	}
+	return position.Abs()
+}
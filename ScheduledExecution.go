@@ -0,0 +1,254 @@
+package binance_modules
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const dayMilliseconds = int64(24 * time.Hour / time.Millisecond)
+
+// ScheduleRequest splits the parent order into slices sent over duration
+type ScheduleRequest struct {
+	ExecutionRequest
+	Duration         time.Duration
+	Slices           int
+	Randomization    float64  // 0..1, share of slice interval and size which is randomized
+	MaxParticipation float64  // max share of market volume since start, zero disables, requires Candles
+	Candles          *Candles // streamed candles, VWAP takes the intraday volume curve from their history
+}
+
+func (r *ScheduleRequest) validate() error {
+	if r.Duration <= 0 || r.Slices <= 0 || r.Duration/time.Duration(r.Slices) < time.Millisecond {
+		return fmt.Errorf("%w: duration %v can't be split into %d slices", ErrInvalidOrderRequest, r.Duration, r.Slices)
+	}
+	if r.Randomization < 0 || r.Randomization > 1 {
+		return fmt.Errorf("%w: randomization must be in [0, 1]", ErrInvalidOrderRequest)
+	}
+	if r.MaxParticipation < 0 || (r.MaxParticipation > 0 && r.Candles == nil) {
+		return fmt.Errorf("%w: participation cap requires candles", ErrInvalidOrderRequest)
+	}
+	return nil
+}
+
+// ScheduledExecution sends a child order at each slice time for the quantity the schedule is behind.
+// Children are MARKET orders, or LIMIT IOC at the limit price when it is set, so nothing rests on the book.
+// Pause shifts the rest of the schedule, quantity skipped because of limits or failed children is caught up
+// by the next slices. What is left after the last slice is abandoned and reported as Shortfall of the progress.
+type ScheduledExecution struct {
+	*execution
+	schedule *ScheduleRequest
+	start    time.Time
+	offsets  []time.Duration   // slice times from start
+	targets  []decimal.Decimal // cumulative quantity to be executed by each slice
+	next     int
+}
+
+// TWAP executes equal slices spread evenly over the duration
+func (op *OrderProvider) TWAP(ctx context.Context, request *ScheduleRequest) (*ScheduledExecution, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]decimal.Decimal, request.Slices)
+	for i := range weights {
+		weights[i] = decimal.NewFromInt(1)
+	}
+	return op.startSchedule(ctx, request, weights)
+}
+
+// VWAP sizes slices by historical volume traded at the same time of day,
+// candles should cover several days to make the curve meaningful
+func (op *OrderProvider) VWAP(ctx context.Context, request *ScheduleRequest) (*ScheduledExecution, error) {
+	err := request.validate()
+	if err != nil {
+		return nil, err
+	}
+	if request.Candles == nil {
+		return nil, fmt.Errorf("%w: VWAP requires candles", ErrInvalidOrderRequest)
+	}
+	interval := request.Duration / time.Duration(request.Slices)
+	return op.startSchedule(ctx, request, volumeCurve(request.Candles, time.Now(), interval, request.Slices))
+}
+
+func (op *OrderProvider) startSchedule(ctx context.Context, request *ScheduleRequest, weights []decimal.Decimal) (*ScheduledExecution, error) {
+	e, err := op.newExecution(&request.ExecutionRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := request.Duration / time.Duration(request.Slices)
+	offsets := make([]time.Duration, request.Slices)
+	total := decimal.Zero
+	for i := range weights {
+		offsets[i] = time.Duration(i)*interval + time.Duration(rand.Float64()*request.Randomization*float64(interval))
+		weights[i] = weights[i].Mul(decimal.NewFromFloat(1 + request.Randomization*(2*rand.Float64()-1)))
+		total = total.Add(weights[i])
+	}
+
+	targets := make([]decimal.Decimal, request.Slices)
+	cumulative := decimal.Zero
+	for i, weight := range weights {
+		cumulative = cumulative.Add(weight)
+		if total.IsPositive() {
+			targets[i] = request.Quantity.Mul(cumulative).Div(total)
+		} else {
+			targets[i] = request.Quantity.Mul(decimal.NewFromInt(int64(i + 1))).Div(decimal.NewFromInt(int64(request.Slices)))
+		}
+	}
+	targets[len(targets)-1] = request.Quantity
+
+	scheduled := &ScheduledExecution{
+		execution: e,
+		schedule:  request,
+		start:     time.Now(),
+		offsets:   offsets,
+		targets:   targets,
+	}
+	go scheduled.run(ctx)
+	return scheduled, nil
+}
+
+func (e *ScheduledExecution) run(ctx context.Context) {
+	defer e.finish()
+
+	for {
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait, ok := e.untilSlice(); ok {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			e.lock.Lock()
+			e.state = ExecutionStateCanceled
+			e.lock.Unlock()
+		case <-e.stopC:
+		case <-timerC:
+			e.slice(ctx)
+		case <-e.notifyC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		finished := e.sync(ctx)
+		e.report()
+		if finished {
+			return
+		}
+	}
+}
+
+func (e *ScheduledExecution) untilSlice() (time.Duration, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.state != ExecutionStateRunning || e.next >= len(e.offsets) {
+		return 0, false
+	}
+	return time.Until(e.start.Add(e.pausedFor + e.offsets[e.next])), true
+}
+
+// sync returns true when the execution is over
+func (e *ScheduledExecution) sync(ctx context.Context) bool {
+	select {
+	case <-e.stopC:
+		return true
+	default:
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if ctx.Err() != nil || e.state == ExecutionStateCanceled || e.state == ExecutionStateDone {
+		return true
+	}
+	if !e.inflight().IsZero() {
+		return false
+	}
+	remaining := e.progress().Remaining()
+	_, executable := e.executable(e.orderType(), remaining)
+	if !executable || e.next >= len(e.offsets) {
+		e.state = ExecutionStateDone
+		if executable {
+			e.log.WithFields(logrus.Fields{
+				"symbol":    e.request.Symbol.Symbol,
+				"shortfall": remaining.String(),
+			}).Warn("Schedule is over with unexecuted quantity")
+		}
+		return true
+	}
+	return false
+}
+
+func (e *ScheduledExecution) slice(ctx context.Context) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.state != ExecutionStateRunning || e.next >= len(e.offsets) {
+		return
+	}
+	target := e.targets[e.next]
+	e.next++
+
+	filled := e.progress().Filled.Add(e.inflight())
+	quantity := target.Sub(filled)
+	if e.schedule.MaxParticipation > 0 {
+		volume := e.schedule.Candles.VolumeSince(e.start.UnixMilli())
+		allowed := volume.Mul(decimal.NewFromFloat(e.schedule.MaxParticipation)).Sub(filled)
+		quantity = decimal.Min(quantity, allowed)
+	}
+	quantity, ok := e.executable(e.orderType(), quantity)
+	if !ok {
+		return
+	}
+
+	request := NewOrderRequest(e.request.Symbol, e.request.Side, e.orderType()).WithQuantity(quantity)
+	if e.orderType() == futures.OrderTypeLimit {
+		request.WithPrice(e.request.LimitPrice).WithTimeInForce(futures.TimeInForceTypeIOC)
+	}
+	err := e.submit(ctx, request)
+	if err == nil {
+		e.log.WithFields(logrus.Fields{
+			"symbol":   e.request.Symbol.Symbol,
+			"slice":    e.next,
+			"slices":   len(e.offsets),
+			"quantity": quantity.String(),
+		}).Debug("Slice sent")
+	}
+}
+
+func (e *ScheduledExecution) orderType() futures.OrderType {
+	if e.request.LimitPrice.IsZero() {
+		return futures.OrderTypeMarket
+	}
+	return futures.OrderTypeLimit
+}
+
+// volumeCurve sums historical volume traded in the time of day window of each slice
+func volumeCurve(candles *Candles, start time.Time, interval time.Duration, slices int) []decimal.Decimal {
+	weights := make([]decimal.Decimal, slices)
+	for i := range weights {
+		weights[i] = decimal.Zero
+	}
+
+	step := interval.Milliseconds()
+	end := step * int64(slices)
+	startOfDay := start.UnixMilli() % dayMilliseconds
+	for _, candle := range candles.Candles() {
+		offset := (candle.OpenTime%dayMilliseconds - startOfDay + dayMilliseconds) % dayMilliseconds
+		// schedules longer than a day take the same time of day more than once
+		for ; offset < end; offset += dayMilliseconds {
+			weights[offset/step] = weights[offset/step].Add(candle.Volume)
+		}
+	}
+	return weights
+}
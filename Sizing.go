@@ -0,0 +1,152 @@
+package binance_modules
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// SizingRequest describes the entry a quantity is computed for
+type SizingRequest struct {
+	Symbol       futures.Symbol
+	Side         futures.SideType
+	PositionSide futures.PositionSideType
+	Type         futures.OrderType // MARKET or LIMIT, MARKET if empty, selects lot size filter
+	Price        decimal.Decimal   // entry price, zero takes mark price
+	StopPrice    decimal.Decimal   // required by risk and Kelly sizing
+	Leverage     int               // zero takes leverage of the position
+	FeeRate      decimal.Decimal   // paid on entry and exit, e.g. 0.0004 for taker
+}
+
+// SizeByRisk returns quantity losing riskPercent of margin balance with fees when the stop is hit
+func (s *Status) SizeByRisk(request SizingRequest, riskPercent decimal.Decimal) (decimal.Decimal, error) {
+	if !riskPercent.IsPositive() || riskPercent.GreaterThan(hundred) {
+		return decimal.Zero, fmt.Errorf("%w: risk percent %s", ErrInvalidOrderRequest, riskPercent)
+	}
+	price, err := s.sizingPrice(request)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !request.StopPrice.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: stop price is required", ErrInvalidOrderRequest)
+	}
+	if (request.Side == futures.SideTypeBuy) != request.StopPrice.LessThan(price) {
+		return decimal.Zero, fmt.Errorf("%w: stop price %s is on the wrong side of %s", ErrInvalidOrderRequest, request.StopPrice, price)
+	}
+
+	total, _ := s.Margin()
+	risk := total.Mul(riskPercent).Div(hundred)
+	lossPerUnit := price.Sub(request.StopPrice).Abs().Add(price.Add(request.StopPrice).Mul(request.FeeRate))
+	return s.sizeQuantity(request, price, risk.Div(lossPerUnit))
+}
+
+// SizeByNotional returns quantity of the notional value, fees of the entry are included in it
+func (s *Status) SizeByNotional(request SizingRequest, notional decimal.Decimal) (decimal.Decimal, error) {
+	if !notional.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: notional must be positive", ErrInvalidOrderRequest)
+	}
+	price, err := s.sizingPrice(request)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return s.sizeQuantity(request, price, notional.Div(price.Mul(decimal.NewFromInt(1).Add(request.FeeRate))))
+}
+
+// SizeByKelly risks fraction of the Kelly criterion, winRate is 0..1 and payoff is average win / average loss
+func (s *Status) SizeByKelly(request SizingRequest, winRate, payoff, fraction decimal.Decimal) (decimal.Decimal, error) {
+	if winRate.IsNegative() || winRate.GreaterThan(decimal.NewFromInt(1)) || !payoff.IsPositive() || !fraction.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: win rate %s, payoff %s, fraction %s", ErrInvalidOrderRequest, winRate, payoff, fraction)
+	}
+	kelly := winRate.Sub(decimal.NewFromInt(1).Sub(winRate).Div(payoff))
+	if !kelly.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: Kelly criterion %s has no edge", ErrInvalidOrderRequest, kelly)
+	}
+	return s.SizeByRisk(request, decimal.Min(kelly.Mul(fraction), decimal.NewFromInt(1)).Mul(hundred))
+}
+
+func (s *Status) sizingPrice(request SizingRequest) (decimal.Decimal, error) {
+	if request.Side != futures.SideTypeBuy && request.Side != futures.SideTypeSell {
+		return decimal.Zero, fmt.Errorf("%w: side %q", ErrInvalidOrderRequest, request.Side)
+	}
+	price := request.Price
+	if price.IsZero() {
+		price = s.MarkPrice()
+	}
+	if !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: entry price is unknown", ErrInvalidOrderRequest)
+	}
+	return price, nil
+}
+
+// sizeQuantity caps quantity by available margin and the leverage bracket, then applies symbol filters
+func (s *Status) sizeQuantity(request SizingRequest, price, quantity decimal.Decimal) (decimal.Decimal, error) {
+	leverage := request.Leverage
+	if leverage == 0 {
+		leverage, _ = strconv.Atoi(s.Position(request.PositionSide).Leverage)
+	}
+	if leverage <= 0 {
+		return decimal.Zero, fmt.Errorf("%w: leverage is unknown", ErrInvalidOrderRequest)
+	}
+	leverageDecimal := decimal.NewFromInt(int64(leverage))
+
+	// initial margin and entry fee must fit available margin
+	costPerUnit := price.Div(leverageDecimal).Add(price.Mul(request.FeeRate))
+	_, available := s.Margin()
+	quantity = decimal.Min(quantity, available.Div(costPerUnit))
+
+	if maxNotional, ok := s.maxNotional(leverage); ok {
+		current := s.PositionAmount(request.PositionSide).Abs().Mul(price)
+		quantity = decimal.Min(quantity, maxNotional.Sub(current).Div(price))
+	}
+
+	orderType := request.Type
+	if orderType == "" {
+		orderType = futures.OrderTypeMarket
+	}
+	order := NewOrderRequest(request.Symbol, request.Side, orderType).
+		WithPositionSide(request.PositionSide).
+		WithQuantity(decimal.Max(quantity, decimal.Zero))
+	switch orderType {
+	case futures.OrderTypeMarket:
+	case futures.OrderTypeLimit:
+		order.WithPrice(price).WithTimeInForce(futures.TimeInForceTypeGTC)
+	default:
+		return decimal.Zero, fmt.Errorf("%w: sizing of %s orders", ErrInvalidOrderRequest, orderType)
+	}
+	if _, filter := lotSizeFilter(order); filter != nil {
+		maxQuantity := parseDecimal(filter.MaxQuantity)
+		if maxQuantity.IsPositive() {
+			order.Quantity = decimal.Min(order.Quantity, maxQuantity)
+		}
+	}
+
+	// open orders are not checked, the provider does it on submit
+	err := NewOrderValidator().Validate(order, price, 0)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return order.Quantity, nil
+}
+
+// maxNotional returns the largest position notional allowed by leverage brackets for the leverage
+func (s *Status) maxNotional(leverage int) (decimal.Decimal, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var (
+		maxNotional = decimal.Zero
+		found       bool
+	)
+	for _, bracket := range s.Brackets {
+		notionalCap := decimal.NewFromFloat(bracket.NotionalCap)
+		if bracket.InitialLeverage >= leverage && notionalCap.GreaterThan(maxNotional) {
+			maxNotional = notionalCap
+			found = true
+		}
+	}
+	return maxNotional, found
+}
@@ -3,33 +3,107 @@ package binance_modules
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"sync"
+	"time"
 
 	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
+const positionTransitionsLimit = 1000
+
+type PositionState string
+
+const (
+	PositionStateClosed  PositionState = "CLOSED"
+	PositionStateOpening PositionState = "OPENING"
+	PositionStateOpened  PositionState = "OPENED"
+	PositionStateClosing PositionState = "CLOSING"
+)
+
+type PositionTransition struct {
+	Time   int64 // ms
+	Symbol string
+	Side   futures.PositionSideType
+	Old    PositionState
+	New    PositionState
+}
+
+// Status fields are written by the account streams and reconciliation under the lock,
+// other goroutines read them with the accessors which return copies
 type Status struct {
-	symbol   string
-	Balance  *futures.Balance
-	Position *futures.PositionRisk
-	Orders   []*futures.Order
-	log      *Logger
+	symbol          string
+	lock            sync.RWMutex
+	DualSide        bool
+	MultiAssets     bool
+	Balance         *futures.Balance
+	Collateral      map[string]*futures.Balance
+	TotalMargin     string
+	AvailableMargin string
+	positions       map[futures.PositionSideType]*futures.PositionRisk
+	Brackets        []futures.Bracket
+	FundingRate     string
+	NextFundingTime int64
+	Orders          *OrderRegistry
+	states          map[futures.PositionSideType]PositionState
+	transitions     []PositionTransition
+	handlersLock    sync.RWMutex
+	orderHandlers   map[int]OrderHandler
+	nextHandlerID   int
+	account         *Account // owner of the status, nil for standalone statuses
+	log             *Logger
 }
 
-func NewStatus(client *futures.Client, symbol string) (*Status, error) {
+// OrderHandler is called from the user data stream and after REST responses of a tracking provider, it must not block
+type OrderHandler func(order *futures.Order)
+
+func newStatus(symbol, marginAsset string, lg *Logger) *Status {
 	status := new(Status)
+	status.log = lg
+	status.symbol = symbol
+	status.Balance = &futures.Balance{Asset: marginAsset, Balance: "0", CrossWalletBalance: "0"}
+	status.Collateral = map[string]*futures.Balance{marginAsset: status.Balance}
+	status.TotalMargin = "0"
+	status.AvailableMargin = "0"
+	status.positions = make(map[futures.PositionSideType]*futures.PositionRisk)
+	status.Orders = NewOrderRegistry()
+	status.states = make(map[futures.PositionSideType]PositionState)
+	status.orderHandlers = make(map[int]OrderHandler)
+	return status
+}
+
+func NewStatus(ctx context.Context, client *futures.Client, symbol string) (*Status, error) {
+	policy := DefaultRetryPolicy.withTimeSync(client)
 	lg, err := GetLogger()
 	if err != nil {
 		fmt.Printf(err.Error())
-		return status, err
+		return new(Status), err
 	}
-	status.log = lg
-	status.symbol = symbol
+	exInfo, err := GetExchangeInfo(ctx, client)
+	if err != nil {
+		lg.WithFields(logrus.Fields{
+			"symbol": symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get exchange info")
+		return new(Status), err
+	}
+	status := newStatus(symbol, exInfo.MarginAsset(symbol), lg)
 
-	underlyingAsset := symbol[len(symbol)-4:]
+	status.MultiAssets, err = retry(ctx, policy, func(ctx context.Context) (bool, error) {
+		return getMultiAssetsMode(ctx, client)
+	})
+	if err != nil {
+		status.log.WithFields(logrus.Fields{
+			"symbol": status.symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get multi-assets mode")
+		return status, err
+	}
 
-	balances, err := client.NewGetBalanceService().Do(context.Background())
+	balances, err := retry(ctx, policy, func(ctx context.Context) ([]*futures.Balance, error) {
+		return client.NewGetBalanceService().Do(ctx)
+	})
 	if err != nil {
 		status.log.WithFields(logrus.Fields{
 			"symbol": status.symbol,
@@ -38,18 +112,44 @@ func NewStatus(client *futures.Client, symbol string) (*Status, error) {
 
 		return status, err
 	}
-	for _, balance := range balances {
-		if balance.Asset == underlyingAsset {
-			status.Balance = balance
-			status.log.WithFields(logrus.Fields{
-				"symbol":  status.symbol,
-				"balance": balance.Asset,
-			}).Info("Successfully got balance")
-			break
-		}
+	status.setCollateral(balances)
+	status.log.WithFields(logrus.Fields{
+		"symbol":      status.symbol,
+		"balance":     status.Balance.Asset,
+		"multiAssets": status.MultiAssets,
+	}).Info("Successfully got balance")
+
+	margin, err := retry(ctx, policy, func(ctx context.Context) (*accountMargin, error) {
+		return getAccountMargin(ctx, client)
+	})
+	if err != nil {
+		status.log.WithFields(logrus.Fields{
+			"symbol": status.symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get account margin")
+		return status, err
 	}
+	status.setMargin(margin)
 
-	positions, err := client.NewGetPositionRiskService().Symbol(symbol).Do(context.Background())
+	positionMode, err := retry(ctx, policy, func(ctx context.Context) (*futures.PositionMode, error) {
+		return client.NewGetPositionModeService().Do(ctx)
+	})
+	if err != nil {
+		status.log.WithFields(logrus.Fields{
+			"symbol": status.symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get position mode")
+		return status, err
+	}
+	status.DualSide = positionMode.DualSidePosition
+	status.log.WithFields(logrus.Fields{
+		"symbol":   status.symbol,
+		"dualSide": status.DualSide,
+	}).Info("Successfully got position mode")
+
+	positions, err := retry(ctx, policy, func(ctx context.Context) ([]*futures.PositionRisk, error) {
+		return client.NewGetPositionRiskService().Symbol(symbol).Do(ctx)
+	})
 	if err != nil {
 		status.log.WithFields(logrus.Fields{
 			"symbol": status.symbol,
@@ -57,12 +157,44 @@ func NewStatus(client *futures.Client, symbol string) (*Status, error) {
 		}).Error("Failed to get position risk")
 		return status, err
 	}
-	status.Position = positions[0]
+	for _, position := range positions {
+		status.setPosition(position)
+	}
 	status.log.WithFields(logrus.Fields{
 		"symbol": status.symbol,
 	}).Info("Successfully got position for symbol")
 
-	orders, err := client.NewListOpenOrdersService().Symbol(symbol).Do(context.Background())
+	brackets, err := retry(ctx, policy, func(ctx context.Context) ([]*futures.LeverageBracket, error) {
+		return client.NewGetLeverageBracketService().Symbol(symbol).Do(ctx)
+	})
+	if err != nil {
+		status.log.WithFields(logrus.Fields{
+			"symbol": status.symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get leverage brackets")
+		return status, err
+	}
+	if len(brackets) > 0 {
+		status.Brackets = brackets[0].Brackets
+	}
+
+	premiumIndex, err := retry(ctx, policy, func(ctx context.Context) ([]*futures.PremiumIndex, error) {
+		return client.NewPremiumIndexService().Symbol(symbol).Do(ctx)
+	})
+	if err != nil {
+		status.log.WithFields(logrus.Fields{
+			"symbol": status.symbol,
+			"err":    err.Error(),
+		}).Error("Failed to get premium index")
+		return status, err
+	}
+	if len(premiumIndex) > 0 {
+		status.setPremiumIndex(premiumIndex[0])
+	}
+
+	orders, err := retry(ctx, policy, func(ctx context.Context) ([]*futures.Order, error) {
+		return client.NewListOpenOrdersService().Symbol(symbol).Do(ctx)
+	})
 	if err != nil {
 		status.log.WithFields(logrus.Fields{
 			"symbol": status.symbol,
@@ -70,76 +202,390 @@ func NewStatus(client *futures.Client, symbol string) (*Status, error) {
 		}).Error("Failed to get orders for symbol")
 		return status, err
 	}
-	status.Orders = orders
+	status.Orders.Load(orders)
 	status.log.WithFields(logrus.Fields{
 		"symbol": status.symbol,
 	}).Info("Successfully got orders for symbol")
 
+	status.UpdateStates(time.Now().UnixMilli())
+
 	return status, nil
 }
 
 func (s *Status) AccountUpdate(update *futures.WsAccountUpdate) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	for _, balance := range update.Balances {
 		if balance.Asset == s.Balance.Asset {
 			s.Balance = BalanceAdapter(&balance)
-			break
+			s.Collateral[balance.Asset] = s.Balance
+		} else if s.MultiAssets {
+			s.Collateral[balance.Asset] = BalanceAdapter(&balance)
 		}
 	}
 	for _, position := range update.Positions {
 		if position.Symbol == s.symbol {
-			s.Position = PositionAdapter(&position)
+			updated := PositionAdapter(&position)
+			// fields which are not sent by the stream
+			if current, ok := s.positions[position.Side]; ok {
+				updated.Leverage = current.Leverage
+				updated.MaxNotionalValue = current.MaxNotionalValue
+				updated.IsAutoAddMargin = current.IsAutoAddMargin
+				updated.IsolatedMargin = current.IsolatedMargin
+				if updated.MarkPrice == "" {
+					updated.MarkPrice = current.MarkPrice
+				}
+			}
+			s.positions[position.Side] = updated
+			s.updateRisk(position.Side)
 		}
 	}
 }
 
-func (s *Status) OrderUpdate(update *futures.WsOrderTradeUpdate) {
-	for i := range s.Orders {
-		if s.Orders[i].OrderID == update.ID {
-			s.Orders[i] = OrderAdapter(update)
-			return
+func (s *Status) setPremiumIndex(premiumIndex *futures.PremiumIndex) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.FundingRate = premiumIndex.LastFundingRate
+	s.NextFundingTime = premiumIndex.NextFundingTime
+}
+
+func (s *Status) MarkPriceUpdate(update *futures.WsMarkPriceEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	markPrice := parseDecimal(update.MarkPrice)
+	for side, position := range s.positions {
+		amount := parseDecimal(position.PositionAmt)
+		entryPrice := parseDecimal(position.EntryPrice)
+
+		updated := *position
+		updated.MarkPrice = update.MarkPrice
+		updated.UnRealizedProfit = amount.Mul(markPrice.Sub(entryPrice)).String()
+		s.positions[side] = &updated
+		s.updateRisk(side)
+	}
+	s.FundingRate = update.FundingRate
+	s.NextFundingTime = update.NextFundingTime
+}
+
+// MarkPrice returns the last mark price known from positions, 0 if there was no update
+func (s *Status) MarkPrice() decimal.Decimal {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, position := range s.positions {
+		markPrice := parseDecimal(position.MarkPrice)
+		if markPrice.IsPositive() {
+			return markPrice
 		}
 	}
-	s.Orders = append(s.Orders, OrderAdapter(update))
+	return decimal.Zero
+}
+
+func (s *Status) PositionAmount(side futures.PositionSideType) decimal.Decimal {
+	return parseDecimal(s.Position(side).PositionAmt)
+}
+
+func (s *Status) EntryPrice(side futures.PositionSideType) decimal.Decimal {
+	return parseDecimal(s.Position(side).EntryPrice)
+}
+
+func (s *Status) UnrealizedPnL() decimal.Decimal {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	pnl := decimal.Zero
+	for _, position := range s.positions {
+		pnl = pnl.Add(parseDecimal(position.UnRealizedProfit))
+	}
+	return pnl
+}
+
+// Sides returns position sides used by the account's position mode
+func (s *Status) Sides() []futures.PositionSideType {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.DualSide {
+		return []futures.PositionSideType{futures.PositionSideTypeLong, futures.PositionSideTypeShort}
+	}
+	return []futures.PositionSideType{futures.PositionSideTypeBoth}
+}
+
+// Position returns a copy of the position of the side
+func (s *Status) Position(side futures.PositionSideType) *futures.PositionRisk {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	position := *s.position(side)
+	return &position
+}
+
+// Positions returns copies of positions of all sides
+func (s *Status) Positions() map[futures.PositionSideType]*futures.PositionRisk {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	positions := make(map[futures.PositionSideType]*futures.PositionRisk, len(s.positions))
+	for side, position := range s.positions {
+		copied := *position
+		positions[side] = &copied
+	}
+	return positions
+}
+
+// position returns the stored position of the side or an empty one. Caller must hold the lock.
+func (s *Status) position(side futures.PositionSideType) *futures.PositionRisk {
+	if side == "" {
+		side = futures.PositionSideTypeBoth
+	}
+	position, ok := s.positions[side]
+	if !ok {
+		return &futures.PositionRisk{Symbol: s.symbol, PositionSide: string(side), PositionAmt: "0"}
+	}
+	return position
+}
+
+func (s *Status) setPosition(position *futures.PositionRisk) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.positions[futures.PositionSideType(position.PositionSide)] = position
+}
+
+func (s *Status) setMode(dualSide, multiAssets bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.DualSide = dualSide
+	s.MultiAssets = multiAssets
+}
+
+func (s *Status) setBrackets(brackets []futures.Bracket) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Brackets = brackets
+}
+
+// MarginAsset returns the asset the symbol is margined in
+func (s *Status) MarginAsset() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.Balance.Asset
+}
+
+// Margin returns total and available margin balance, in USD value of collateral in multi-assets mode
+func (s *Status) Margin() (total, available decimal.Decimal) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return parseDecimal(s.TotalMargin), parseDecimal(s.AvailableMargin)
+}
+
+func (s *Status) OrderUpdate(update *futures.WsOrderTradeUpdate) {
+	order := OrderAdapter(update)
+	err := s.Orders.Update(order, update.TradeID)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{
+			"symbol":  s.symbol,
+			"orderID": update.ID,
+			"status":  update.Status,
+			"err":     err.Error(),
+		}).Warn("Order update ignored")
+		return
+	}
+
+	s.handlersLock.RLock()
+	defer s.handlersLock.RUnlock()
+	for _, handler := range s.orderHandlers {
+		handler(order)
+	}
+}
+
+// OnOrderUpdate subscribes to accepted order updates of the stream, returned function unsubscribes
+func (s *Status) OnOrderUpdate(handler OrderHandler) func() {
+	s.handlersLock.Lock()
+	defer s.handlersLock.Unlock()
+
+	id := s.nextHandlerID
+	s.nextHandlerID++
+	s.orderHandlers[id] = handler
+	return func() {
+		s.handlersLock.Lock()
+		defer s.handlersLock.Unlock()
+
+		delete(s.orderHandlers, id)
+	}
 }
 
 func (s *Status) CreateOrderUpdate(update *futures.CreateOrderResponse) {
-	s.Orders = append(s.Orders, CreateOrderAdapter(update))
+	order := CreateOrderAdapter(update)
+	err := s.Orders.Update(order, 0)
+	if err != nil {
+		s.log.WithFields(logrus.Fields{
+			"symbol":  s.symbol,
+			"orderID": update.OrderID,
+			"status":  update.Status,
+			"err":     err.Error(),
+		}).Warn("Order update ignored")
+		return
+	}
+	s.applied(order)
+}
+
+// PendingSubmits returns orders sent to exchange without acknowledgement yet
+func (s *Status) PendingSubmits() []*PendingOrder {
+	return s.Orders.Pending()
 }
 
-func (s *Status) PositionStatus() string {
+// CancelOrderUpdate marks the order canceled until the user data event confirms it
+func (s *Status) CancelOrderUpdate(update *futures.CancelOrderResponse) {
+	order := CancelOrderAdapter(update)
+	if s.Orders.UpdateOptimistic(order) {
+		s.applied(order)
+	}
+}
+
+func (s *Status) CancelAllUpdate(t int64) {
+	s.applied(s.Orders.CancelAllOptimistic(t)...)
+}
+
+func (s *Status) ModifyOrderUpdate(order *futures.Order) {
+	if s.Orders.UpdateOptimistic(order) {
+		s.applied(order)
+	}
+}
+
+// QueryOrderUpdate applies order state received from REST if it is not older than the local one
+func (s *Status) QueryOrderUpdate(order *futures.Order) {
+	if s.Orders.Refresh(order) {
+		s.applied(order)
+	}
+}
+
+// applied updates position states and calls order handlers after REST results are applied,
+// like the user data stream does for its updates
+func (s *Status) applied(orders ...*futures.Order) {
+	if len(orders) == 0 {
+		return
+	}
+	if s.account != nil {
+		s.account.updateStates(s)
+	} else {
+		s.UpdateStates(time.Now().UnixMilli())
+	}
+
+	s.handlersLock.RLock()
+	defer s.handlersLock.RUnlock()
+	for _, order := range orders {
+		for _, handler := range s.orderHandlers {
+			handler(order)
+		}
+	}
+}
+
+// State returns position state as of the last UpdateStates call
+func (s *Status) State(side futures.PositionSideType) PositionState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if side == "" {
+		side = futures.PositionSideTypeBoth
+	}
+	state, ok := s.states[side]
+	if !ok {
+		return PositionStateClosed
+	}
+	return state
+}
+
+// UpdateStates recalculates position states and returns transitions since the previous call
+func (s *Status) UpdateStates(t int64) []PositionTransition {
+	var transitions []PositionTransition
+
+	// states are computed without the lock, PositionStatus takes locks of the status and its orders
+	sides := s.Sides()
+	states := make([]PositionState, len(sides))
+	for i, side := range sides {
+		states[i] = s.PositionStatus(side)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, side := range sides {
+		state := states[i]
+		old, ok := s.states[side]
+		if !ok {
+			old = PositionStateClosed
+		}
+		s.states[side] = state
+		if old != state {
+			transitions = append(transitions, PositionTransition{Time: t, Symbol: s.symbol, Side: side, Old: old, New: state})
+		}
+	}
+
+	s.transitions = append(s.transitions, transitions...)
+	if len(s.transitions) > positionTransitionsLimit {
+		s.transitions = s.transitions[len(s.transitions)-positionTransitionsLimit:]
+	}
+	return transitions
+}
+
+func (s *Status) Transitions() []PositionTransition {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]PositionTransition(nil), s.transitions...)
+}
+
+func (s *Status) PositionStatus(side futures.PositionSideType) PositionState {
 	var (
-		status      string
-		positionAmt float64
+		status      PositionState
+		positionAmt decimal.Decimal
 	)
-	positionAmt, _ = strconv.ParseFloat(s.Position.PositionAmt, 64)
-	if positionAmt != 0.0 {
-		for i := range s.Orders {
-			if (s.Orders[i].Status == futures.OrderStatusTypeNew) || (s.Orders[i].Status == futures.OrderStatusTypePartiallyFilled) {
-				if s.Orders[i].ClosePosition == true {
-					status = "CLOSING"
+	if side == "" {
+		side = futures.PositionSideTypeBoth
+	}
+	positionAmt = s.PositionAmount(side)
+	if !positionAmt.IsZero() {
+		for _, order := range s.Orders.Open() {
+			if order.PositionSide != side {
+				continue
+			}
+			if (order.Status == futures.OrderStatusTypeNew) || (order.Status == futures.OrderStatusTypePartiallyFilled) {
+				if order.ClosePosition == true {
+					status = PositionStateClosing
 					return status
 				} else {
-					if (s.Orders[i].Side == futures.SideTypeBuy) && (positionAmt < 0) {
-						status = "CLOSING"
+					if (order.Side == futures.SideTypeBuy) && positionAmt.IsNegative() {
+						status = PositionStateClosing
 						return status
 					}
-					if (s.Orders[i].Side == futures.SideTypeSell) && (positionAmt > 0) {
-						status = "CLOSING"
+					if (order.Side == futures.SideTypeSell) && positionAmt.IsPositive() {
+						status = PositionStateClosing
 						return status
 					}
 				}
 			}
 		}
-		status = "OPENED"
+		status = PositionStateOpened
 		return status
 	} else {
-		for i := range s.Orders {
-			if (s.Orders[i].Status == futures.OrderStatusTypeNew) && (s.Orders[i].ReduceOnly == false) && (s.Orders[i].ClosePosition == false) {
-				status = "OPENING"
+		for _, order := range s.Orders.Open() {
+			if order.PositionSide != side {
+				continue
+			}
+			if (order.Status == futures.OrderStatusTypeNew) && (order.ReduceOnly == false) && (order.ClosePosition == false) {
+				status = PositionStateOpening
 				return status
 			}
 		}
-		status = "CLOSED"
+		status = PositionStateClosed
 		return status
 	}
 }
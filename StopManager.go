@@ -0,0 +1,393 @@
+package binance_modules
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStopMinInterval    = time.Second
+	defaultStopMaxUtilization = 0.8
+)
+
+// StopState is passed to rules, prices are of the position being protected
+type StopState struct {
+	Long        bool
+	Entry       decimal.Decimal
+	InitialStop decimal.Decimal
+	Stop        decimal.Decimal // current stop
+	Price       decimal.Decimal // last price
+	Extreme     decimal.Decimal // best price since start: highest for long, lowest for short
+}
+
+// StopRule proposes a stop price, zero means no proposal. Manager never loosens the stop.
+type StopRule func(state StopState) decimal.Decimal
+
+// FixedTrailing keeps stop at distance from the best price
+func FixedTrailing(distance decimal.Decimal) StopRule {
+	return func(state StopState) decimal.Decimal {
+		if state.Long {
+			return state.Extreme.Sub(distance)
+		}
+		return state.Extreme.Add(distance)
+	}
+}
+
+// ATRTrailing keeps stop at multiplier of ATR from the best price
+func ATRTrailing(candles *Candles, period int, multiplier decimal.Decimal) StopRule {
+	return func(state StopState) decimal.Decimal {
+		atr := candles.ATR(period)
+		if atr.IsZero() {
+			return decimal.Zero
+		}
+		return FixedTrailing(atr.Mul(multiplier))(state)
+	}
+}
+
+// BreakEven moves stop to entry plus offset after profit reaches r multiples of initial risk
+func BreakEven(r, offset decimal.Decimal) StopRule {
+	return func(state StopState) decimal.Decimal {
+		risk := state.Entry.Sub(state.InitialStop).Abs()
+		profit := state.Extreme.Sub(state.Entry)
+		if !state.Long {
+			profit = profit.Neg()
+		}
+		if risk.IsZero() || profit.LessThan(risk.Mul(r)) {
+			return decimal.Zero
+		}
+		if state.Long {
+			return state.Entry.Add(offset)
+		}
+		return state.Entry.Sub(offset)
+	}
+}
+
+// StepTrailing moves stop by step each time the best price moves by step from entry
+func StepTrailing(step decimal.Decimal) StopRule {
+	return func(state StopState) decimal.Decimal {
+		progress := state.Extreme.Sub(state.Entry)
+		if !state.Long {
+			progress = progress.Neg()
+		}
+		steps := progress.Div(step).Floor()
+		if !steps.IsPositive() {
+			return decimal.Zero
+		}
+		if state.Long {
+			return state.InitialStop.Add(steps.Mul(step))
+		}
+		return state.InitialStop.Sub(steps.Mul(step))
+	}
+}
+
+// StopManager keeps a STOP_MARKET order on exchange and moves it by rules on market data.
+// Stop order can't be amended, so a new one is placed before the old one is canceled,
+// moves are throttled by MinInterval and postponed while rate limit usage is above MaxUtilization.
+type StopManager struct {
+	lock           sync.Mutex
+	provider       *OrderProvider
+	status         *Status
+	symbol         futures.Symbol
+	positionSide   futures.PositionSideType
+	quantity       decimal.Decimal
+	state          StopState
+	rules          []StopRule
+	clientOrderID  string
+	stale          []string      // replaced stop orders whose cancel failed, it is retried
+	unknown        *OrderRequest // replacement whose submit outcome is unknown, the current stop is kept until it is resolved
+	unknownStop    decimal.Decimal
+	lastMove       time.Time
+	triggered      bool
+	MinInterval    time.Duration
+	MaxUtilization float64
+	WorkingType    futures.WorkingType
+	log            *Logger
+}
+
+// NewStopManager protects position opened by side at entry price with initial stop
+func (op *OrderProvider) NewStopManager(symbol futures.Symbol, side futures.SideType, positionSide futures.PositionSideType,
+	quantity, entry, stop decimal.Decimal, rules ...StopRule) (*StopManager, error) {
+	// trigger of the stop is detected by the tracked status
+	status := op.tracked(symbol.Symbol)
+	if status == nil {
+		return nil, ErrNotTracked
+	}
+	lg, err := GetLogger()
+	if err != nil {
+		return nil, err
+	}
+	manager := &StopManager{
+		provider:     op,
+		status:       status,
+		symbol:       symbol,
+		positionSide: positionSide,
+		quantity:     quantity,
+		state: StopState{
+			Long:        side == futures.SideTypeBuy,
+			Entry:       entry,
+			InitialStop: stop,
+			Price:       entry,
+			Extreme:     entry,
+		},
+		rules:          rules,
+		MinInterval:    defaultStopMinInterval,
+		MaxUtilization: defaultStopMaxUtilization,
+		log:            lg,
+	}
+	return manager, nil
+}
+
+// Start places the initial stop order
+func (m *StopManager) Start(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.move(ctx, m.state.InitialStop)
+}
+
+func (m *StopManager) Stop() decimal.Decimal {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.state.Stop
+}
+
+// Triggered reports that the stop order was filled and the manager does nothing anymore
+func (m *StopManager) Triggered() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.isTriggered()
+}
+
+// Update takes the last price and moves the stop when rules tighten it
+func (m *StopManager) Update(ctx context.Context, price decimal.Decimal) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !price.IsPositive() || m.clientOrderID == "" || m.isTriggered() {
+		return nil
+	}
+	m.cancelStale(ctx)
+	if m.unknown != nil {
+		// stops are not moved again until it is known which one is live
+		return m.resolveUnknown(ctx)
+	}
+	m.state.Price = price
+	if (m.state.Long && price.GreaterThan(m.state.Extreme)) || (!m.state.Long && price.LessThan(m.state.Extreme)) {
+		m.state.Extreme = price
+	}
+
+	stop := m.state.Stop
+	for _, rule := range m.rules {
+		candidate := rule(m.state)
+		if !candidate.IsPositive() {
+			continue
+		}
+		if (m.state.Long && candidate.GreaterThan(stop)) || (!m.state.Long && candidate.LessThan(stop)) {
+			stop = candidate
+		}
+	}
+	// stop beyond the price would trigger immediately
+	if (m.state.Long && stop.GreaterThanOrEqual(price)) || (!m.state.Long && stop.LessThanOrEqual(price)) {
+		return nil
+	}
+	if filter := m.symbol.PriceFilter(); filter != nil {
+		mode := RoundFloor
+		if !m.state.Long {
+			mode = RoundCeil
+		}
+		stop = roundToStep(stop, filter.TickSize, mode)
+	}
+	if stop.Equal(m.state.Stop) || !m.canMove() {
+		return nil
+	}
+	return m.move(ctx, stop)
+}
+
+func (m *StopManager) MarkPriceUpdate(ctx context.Context, event *futures.WsMarkPriceEvent) error {
+	return m.Update(ctx, parseDecimal(event.MarkPrice))
+}
+
+// OrderBookUpdate uses the price position can be closed at: best bid for long, best ask for short
+func (m *StopManager) OrderBookUpdate(ctx context.Context, orderbook *OrderBook) error {
+	if len(orderbook.Bids) == 0 || len(orderbook.Asks) == 0 {
+		return nil
+	}
+	if m.state.Long {
+		return m.Update(ctx, orderbook.BestBidLevel().Price)
+	}
+	return m.Update(ctx, orderbook.BestAskLevel().Price)
+}
+
+func (m *StopManager) KlineUpdate(ctx context.Context, kline *futures.WsKline) error {
+	return m.Update(ctx, parseDecimal(kline.Close))
+}
+
+// Cancel removes the stop order and replaced ones which are still open from exchange
+func (m *StopManager) Cancel(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.isTriggered()
+	err := m.cancelStale(ctx)
+	if m.unknown != nil {
+		_, cancelErr := m.provider.CancelClientOrder(ctx, m.symbol, m.unknown.ClientOrderID)
+		if cancelErr != nil && !isOrderNotOpen(cancelErr) {
+			return cancelErr
+		}
+		m.status.Orders.RemovePending(m.unknown.ClientOrderID)
+		m.unknown = nil
+	}
+	if m.clientOrderID == "" {
+		return err
+	}
+	_, cancelErr := m.provider.CancelClientOrder(ctx, m.symbol, m.clientOrderID)
+	if cancelErr != nil && !isOrderNotOpen(cancelErr) {
+		return cancelErr
+	}
+	m.clientOrderID = ""
+	return err
+}
+
+// cancelStale retries canceling of replaced stop orders, the ones which failed again are kept
+func (m *StopManager) cancelStale(ctx context.Context) error {
+	var (
+		stale    []string
+		firstErr error
+	)
+	for _, clientOrderID := range m.stale {
+		order := m.status.Orders.GetByClientID(clientOrderID)
+		if order != nil && IsTerminalOrderStatus(order.Status) {
+			continue
+		}
+		_, err := m.provider.CancelClientOrder(ctx, m.symbol, clientOrderID)
+		if err != nil && !isOrderNotOpen(err) {
+			m.log.WithFields(logrus.Fields{
+				"symbol":        m.symbol.Symbol,
+				"clientOrderID": clientOrderID,
+				"err":           err.Error(),
+			}).Error("Failed to cancel previous stop order")
+			stale = append(stale, clientOrderID)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	m.stale = stale
+	return firstErr
+}
+
+func (m *StopManager) canMove() bool {
+	if time.Since(m.lastMove) < m.MinInterval {
+		return false
+	}
+	if limiter := currentRateLimiter(); limiter != nil && m.MaxUtilization > 0 {
+		for _, usage := range limiter.Utilization() {
+			if usage.Ratio() > m.MaxUtilization {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isTriggered checks the current stop and replaced ones which may still be open
+func (m *StopManager) isTriggered() bool {
+	if m.triggered {
+		return true
+	}
+	ids := append([]string{m.clientOrderID}, m.stale...)
+	if m.unknown != nil {
+		ids = append(ids, m.unknown.ClientOrderID)
+	}
+	for _, clientOrderID := range ids {
+		order := m.status.Orders.GetByClientID(clientOrderID)
+		if clientOrderID != "" && order != nil && order.Status == futures.OrderStatusTypeFilled {
+			m.triggered = true
+			break
+		}
+	}
+	return m.triggered
+}
+
+func (m *StopManager) move(ctx context.Context, stop decimal.Decimal) error {
+	side := futures.SideTypeSell
+	if !m.state.Long {
+		side = futures.SideTypeBuy
+	}
+	request := NewOrderRequest(m.symbol, side, futures.OrderTypeStopMarket).
+		WithPositionSide(m.positionSide).
+		WithQuantity(m.quantity).
+		WithStopPrice(stop)
+	if m.WorkingType != "" {
+		request.WithWorkingType(m.WorkingType)
+	}
+	if m.positionSide == "" || m.positionSide == futures.PositionSideTypeBoth {
+		request.ReduceOnly()
+	}
+
+	m.lastMove = time.Now()
+	_, err := m.provider.Submit(ctx, request)
+	if err != nil {
+		if m.status.Orders.IsPending(request.ClientOrderID) {
+			// the new stop may be placed, both stops are kept until the stream or a lookup shows it
+			m.unknown, m.unknownStop = request, stop
+		}
+		return err
+	}
+	m.replace(ctx, request.ClientOrderID, stop)
+	return nil
+}
+
+// resolveUnknown makes the replacement of unknown outcome current once it is found open or filled,
+// it is forgotten when exchange does not know it or it is closed without fill
+func (m *StopManager) resolveUnknown(ctx context.Context) error {
+	request := m.unknown
+	order := m.status.Orders.GetByClientID(request.ClientOrderID)
+	if order == nil {
+		if !m.canMove() {
+			return nil
+		}
+		m.lastMove = time.Now()
+		var err error
+		order, err = m.provider.lookupClientOrder(ctx, request)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			m.status.Orders.RemovePending(request.ClientOrderID)
+			m.unknown = nil
+			return nil
+		}
+	}
+
+	m.unknown = nil
+	if IsTerminalOrderStatus(order.Status) && order.Status != futures.OrderStatusTypeFilled {
+		return nil
+	}
+	m.replace(ctx, request.ClientOrderID, m.unknownStop)
+	return nil
+}
+
+// replace makes the placed stop current and cancels the previous one
+func (m *StopManager) replace(ctx context.Context, clientOrderID string, stop decimal.Decimal) {
+	if m.clientOrderID != "" {
+		// the old stop stays known until its cancel succeeds
+		m.stale = append(m.stale, m.clientOrderID)
+		m.cancelStale(ctx)
+	}
+	m.log.WithFields(logrus.Fields{
+		"symbol": m.symbol.Symbol,
+		"old":    m.state.Stop.String(),
+		"new":    stop.String(),
+	}).Info("Stop order moved")
+
+	m.clientOrderID = clientOrderID
+	m.state.Stop = stop
+}
@@ -1,6 +1,7 @@
 package binance_modules
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -20,14 +21,19 @@ type BaseStrategyInterface interface {
 }
 
 type AccountStrategyInterface interface {
-	InitAccount() error
+	InitAccount(ctx context.Context) error
 	accountUpdateHandler(event *futures.WsUserDataEvent)
-	accountErrorHandler(err error)
+	positionStateHandler(transition PositionTransition)
 	OnAccountUpdate()
 }
 
+// PositionStateStrategy is optionally implemented by account strategies to receive position state changes
+type PositionStateStrategy interface {
+	OnPositionStateChange(side futures.PositionSideType, old, new PositionState)
+}
+
 type OrderBookStrategyInterface interface {
-	InitOrderBook() error
+	InitOrderBook(ctx context.Context) error
 	depthUpdateHandler(event *futures.WsDepthEvent)
 	depthErrorHandler(err error)
 	OnDepthUpdate()
@@ -40,25 +46,40 @@ type ClusterStrategyInterface interface {
 	OnCLusterUpdate()
 }
 
+type MarkPriceStrategyInterface interface {
+	InitMarkPrice() error
+	markPriceUpdateHandler(event *futures.WsMarkPriceEvent)
+	markPriceErrorHandler(err error)
+	OnMarkPriceUpdate()
+}
+
 type CandlesStrategyInterface interface {
-	InitCandles() error
+	InitCandles(ctx context.Context) error
 	candleUpdateHandler(event *futures.WsKlineEvent)
 	candleErrorHandler(err error)
 	OnCandleUpdate()
 }
 
 type BaseStrategy struct {
-	Client *futures.Client
-	Symbol *futures.Symbol
-	On     bool
-	log    *Logger
+	Name      string
+	Client    *futures.Client
+	Symbol    *futures.Symbol
+	On        bool
+	DataGuard *DataGuard // optional, streams report event times to it and it blocks orders of NewOrderProvider
+	log       *Logger
 }
 
 type AccountStrategy struct {
 	AccountStrategyInterface
-	Account      *Status
-	accountDoneC chan struct{}
-	accountStopC chan struct{}
+	Account *Status
+	account *Account
+}
+
+type MarkPriceStrategy struct {
+	MarkPriceStrategyInterface
+	MarkPrice      *futures.WsMarkPriceEvent
+	markPriceStopC chan struct{}
+	markPriceDoneC chan struct{}
 }
 
 type OrderBookStrategy struct {
@@ -92,6 +113,7 @@ type AbstractStrategy struct {
 	OrderBookStrategy
 	ClusterStrategy
 	CandlesStrategy
+	MarkPriceStrategy
 }
 
 func (AS *AbstractStrategy) SetClient(client *futures.Client) {
@@ -113,80 +135,89 @@ func (AS *AbstractStrategy) SetLogger() error {
 
 func (AS *AbstractStrategy) Activate() {
 	AS.On = true
+	// positions opened before activation are reported once the strategy is on
+	if AS.account != nil {
+		for _, transition := range AS.account.PositionStates(AS.Symbol.Symbol) {
+			AS.positionStateHandler(transition)
+		}
+	}
 }
 
-func (AS *AbstractStrategy) InitAccount() error {
-	var err error
-	AS.Account, err = NewStatus(AS.Client, AS.Symbol.Symbol)
-	if err != nil {
-		AS.log.WithFields(logrus.Fields{
-			"symbol": AS.Symbol.Symbol,
-			"err":    err.Error(),
-		}).Error("Failed to initialize account")
-		return err
+func (AS *AbstractStrategy) watch(stream DataStream) {
+	if AS.DataGuard != nil {
+		AS.DataGuard.Watch(stream)
 	}
+}
 
-	listenKey, err := GetListenKey(AS.Client)
-	if err != nil {
-		AS.log.WithFields(logrus.Fields{
-			"symbol": AS.Symbol.Symbol,
-			"err":    err.Error(),
-		}).Error("Failed to get Listen Key")
-		return err
+func (AS *AbstractStrategy) observe(stream DataStream, eventTime int64) {
+	if AS.DataGuard != nil {
+		AS.DataGuard.Observe(stream, eventTime)
 	}
-	doneC, stopC, err := futures.WsUserDataServe(listenKey.listenKey, AS.accountUpdateHandler, AS.accountErrorHandler)
+}
+
+func (AS *AbstractStrategy) InitAccount(ctx context.Context) error {
+	account, err := GetAccount(ctx, AS.Client)
 	if err != nil {
 		AS.log.WithFields(logrus.Fields{
 			"symbol": AS.Symbol.Symbol,
 			"err":    err.Error(),
-		}).Error("Failed to initialize User Data Stream")
+		}).Error("Failed to initialize account")
 		return err
 	}
-	AS.accountDoneC = doneC
-	AS.accountStopC = stopC
+	AS.account = account
+	AS.Account = account.Register(AS.Symbol.Symbol, AS.accountUpdateHandler)
+	account.OnPositionStateChange(AS.Symbol.Symbol, AS.positionStateHandler)
+	if AS.Name != "" {
+		account.Assign(AS.Name)
+	}
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 	}).Info("Successfully initialized account")
 	return nil
 }
 
+// NewOrderProvider returns a provider tracking the account whose orders are attributed to the strategy
+// by client order id prefix and blocked by the data guard of the strategy while market data is stale
+func (AS *AbstractStrategy) NewOrderProvider() OrderProvider {
+	provider := NewOrderProvider(AS.Client)
+	if AS.Name != "" {
+		provider.SetClientOrderIDPrefix(AS.Name)
+	}
+	if AS.account != nil {
+		provider.TrackAccount(AS.account)
+	}
+	if AS.DataGuard != nil {
+		provider.SetDataGuard(AS.DataGuard)
+	}
+	return provider
+}
+
 func (AS *AbstractStrategy) accountUpdateHandler(event *futures.WsUserDataEvent) {
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 		"Event":  event.Event,
 	}).Info("User Data Event recieved")
 
-	if event.Event == futures.UserDataEventTypeAccountUpdate {
-		AS.Account.AccountUpdate(&event.AccountUpdate)
-	}
-	if event.Event == futures.UserDataEventTypeOrderTradeUpdate {
-		AS.Account.OrderUpdate(&event.OrderTradeUpdate)
-	}
-	if event.Event == futures.UserDataEventTypeListenKeyExpired {
-		AS.InitAccount()
-	}
 	if AS.On {
 		AS.OnAccountUpdate()
 	}
 }
 
-func (AS *AbstractStrategy) accountErrorHandler(err error) {
+func (AS *AbstractStrategy) positionStateHandler(transition PositionTransition) {
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
-		"err":    err.Error(),
-	}).Error("User Data Stream error. Restarting...")
-
-	<-AS.accountDoneC
-	err = AS.InitAccount()
-	if err != nil {
-		AS.log.WithFields(logrus.Fields{
-			"symbol": AS.Symbol.Symbol,
-			"err":    err.Error(),
-		}).Fatal("Failed to restart Account Updates")
+		"side":   transition.Side,
+		"old":    transition.Old,
+		"new":    transition.New,
+	}).Info("Position state changed")
+
+	strategy, ok := AS.AccountStrategyInterface.(PositionStateStrategy)
+	if AS.On && ok {
+		strategy.OnPositionStateChange(transition.Side, transition.Old, transition.New)
 	}
 }
 
-func (AS *AbstractStrategy) InitOrderBook() error {
+func (AS *AbstractStrategy) InitOrderBook(ctx context.Context) error {
 	AS.conn = make(chan *futures.WsDepthEvent, 10)
 	doneC, stopC, err := futures.WsDiffDepthServeWithRate(AS.Symbol.Symbol, 100*time.Millisecond, AS.depthUpdateHandler, AS.depthErrorHandler)
 	if err != nil {
@@ -196,7 +227,7 @@ func (AS *AbstractStrategy) InitOrderBook() error {
 		}).Error("Failed to initialize DiffDepth stream")
 		return err
 	}
-	AS.Orderbook, err = NewOrderBook(AS.Client, AS.Symbol.Symbol, 1000)
+	AS.Orderbook, err = NewOrderBook(ctx, AS.Client, AS.Symbol.Symbol, 1000)
 	if err != nil {
 		AS.log.WithFields(logrus.Fields{
 			"symbol": AS.Symbol.Symbol,
@@ -216,6 +247,7 @@ func (AS *AbstractStrategy) InitOrderBook() error {
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 	}).Info("Successfully initialized orderbook")
+	AS.watch(DataStreamDepth)
 	return nil
 }
 
@@ -225,6 +257,7 @@ func (AS *AbstractStrategy) depthUpdateHandler(event *futures.WsDepthEvent) {
 		updated int
 	)
 
+	AS.observe(DataStreamDepth, event.Time)
 	AS.conn <- event
 	if AS.Orderbook != nil {
 		for update = range AS.conn {
@@ -244,7 +277,7 @@ func (AS *AbstractStrategy) depthUpdateHandler(event *futures.WsDepthEvent) {
 					"symbol": AS.Symbol.Symbol,
 				}).Error("Restarting orderbook...")
 
-				err := AS.InitOrderBook()
+				err := AS.InitOrderBook(context.Background())
 				if err != nil {
 					AS.log.WithFields(logrus.Fields{
 						"symbol": AS.Symbol.Symbol,
@@ -276,7 +309,7 @@ func (AS *AbstractStrategy) depthErrorHandler(err error) {
 	AS.Orderbook = nil
 	<-AS.orderbookStopC
 	<-AS.orderbookDoneC
-	err = AS.InitOrderBook()
+	err = AS.InitOrderBook(context.Background())
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 	}).Fatal("Failed to restart DiffDepth stream")
@@ -297,10 +330,12 @@ func (AS *AbstractStrategy) InitClusters() error {
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 	}).Info("Successfully initialized clusters")
+	AS.watch(DataStreamAggTrade)
 	return nil
 }
 
 func (AS *AbstractStrategy) tradeUpdateHandler(event *futures.WsAggTradeEvent) {
+	AS.observe(DataStreamAggTrade, event.Time)
 	AS.Clusters.Update(event)
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
@@ -327,8 +362,8 @@ func (AS *AbstractStrategy) tradeErrorHandler(err error) {
 	}
 }
 
-func (AS *AbstractStrategy) InitCandles() error {
-	candles, err := NewCandles(AS.Client, AS.Symbol.Symbol, strconv.Itoa(AS.CandlesStrategy.TimeFrame)+"m", AS.InitCandlesNum)
+func (AS *AbstractStrategy) InitCandles(ctx context.Context) error {
+	candles, err := NewCandles(ctx, AS.Client, AS.Symbol.Symbol, strconv.Itoa(AS.CandlesStrategy.TimeFrame)+"m", AS.InitCandlesNum)
 	if err != nil {
 		AS.log.WithFields(logrus.Fields{
 			"symbol": AS.Symbol.Symbol,
@@ -351,11 +386,13 @@ func (AS *AbstractStrategy) InitCandles() error {
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
 	}).Info("Successfully initialized candles")
+	AS.watch(DataStreamKline)
 
 	return nil
 }
 
 func (AS *AbstractStrategy) candleUpdateHandler(event *futures.WsKlineEvent) {
+	AS.observe(DataStreamKline, event.Time)
 	AS.Candles.Update(&event.Kline)
 	AS.log.WithFields(logrus.Fields{
 		"symbol": AS.Symbol.Symbol,
@@ -373,7 +410,7 @@ func (AS *AbstractStrategy) candleErrorHandler(err error) {
 	<-AS.candlesStopC
 	<-AS.candlesDoneC
 	AS.Candles = nil
-	err = AS.InitCandles()
+	err = AS.InitCandles(context.Background())
 	if err != nil {
 		AS.log.WithFields(logrus.Fields{
 			"symbol": AS.Symbol.Symbol,
@@ -381,3 +418,51 @@ func (AS *AbstractStrategy) candleErrorHandler(err error) {
 		}).Fatal("Failed to restart candles")
 	}
 }
+
+func (AS *AbstractStrategy) InitMarkPrice() error {
+	doneC, stopC, err := futures.WsMarkPriceServeWithRate(AS.Symbol.Symbol, time.Second, AS.markPriceUpdateHandler, AS.markPriceErrorHandler)
+	if err != nil {
+		AS.log.WithFields(logrus.Fields{
+			"symbol": AS.Symbol.Symbol,
+			"err":    err.Error(),
+		}).Error("Failed to initialize MarkPrice stream")
+		return err
+	}
+	AS.markPriceDoneC = doneC
+	AS.markPriceStopC = stopC
+	AS.log.WithFields(logrus.Fields{
+		"symbol": AS.Symbol.Symbol,
+	}).Info("Successfully initialized mark price")
+	AS.watch(DataStreamMarkPrice)
+	return nil
+}
+
+func (AS *AbstractStrategy) markPriceUpdateHandler(event *futures.WsMarkPriceEvent) {
+	AS.observe(DataStreamMarkPrice, event.Time)
+	AS.MarkPrice = event
+	if AS.account != nil {
+		AS.account.MarkPriceUpdate(event)
+	}
+	if AS.On {
+		AS.OnMarkPriceUpdate()
+	}
+}
+
+func (AS *AbstractStrategy) markPriceErrorHandler(err error) {
+	AS.log.WithFields(logrus.Fields{
+		"symbol": AS.Symbol.Symbol,
+		"err":    err,
+	}).Error("MarkPrice stream error. Restarting...")
+
+	// error handler is called from the stream goroutine, doneC is closed after it returns
+	go func(doneC chan struct{}) {
+		<-doneC
+		err := AS.InitMarkPrice()
+		if err != nil {
+			AS.log.WithFields(logrus.Fields{
+				"symbol": AS.Symbol.Symbol,
+				"err":    err,
+			}).Fatal("Failed to restart mark price")
+		}
+	}(AS.markPriceDoneC)
+}
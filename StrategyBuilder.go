@@ -1,6 +1,8 @@
 package binance_modules
 
 import (
+	"context"
+
 	"github.com/adshao/go-binance/v2/futures"
 )
 
@@ -9,19 +11,31 @@ type StrategyBuilder struct {
 	symbol *futures.Symbol
 }
 
-func NewStrategyBuilder(client *futures.Client, symbol string) (*StrategyBuilder, error) {
+func NewStrategyBuilder(ctx context.Context, client *futures.Client, symbol string) (*StrategyBuilder, error) {
 	builder := new(StrategyBuilder)
 	builder.client = client
 
-	exInfo, err := GetExchangeInfo(client)
+	exInfo, err := GetExchangeInfo(ctx, client)
+	if err != nil {
+		return builder, err
+	}
+
+	limiter, err := GetRateLimiter(ctx, client)
 	if err != nil {
 		return builder, err
 	}
+	limiter.Install(client)
 
 	builder.symbol = exInfo.Symbol(symbol)
 	return builder, nil
 }
 
+// EnsureAccountSettings brings account settings to the ones the strategy needs before it is launched
+func (SB *StrategyBuilder) EnsureAccountSettings(ctx context.Context, settings AccountSettings) error {
+	provider := NewOrderProvider(SB.client)
+	return provider.EnsureAccountSettings(ctx, *SB.symbol, settings)
+}
+
 func (SB *StrategyBuilder) RegisterStrategy(strategy BaseStrategyInterface) {
 	strategy.SetClient(SB.client)
 	strategy.SetSymbol(SB.symbol)
@@ -32,15 +46,15 @@ func (SB *StrategyBuilder) InitializeStrategy(strategy Strategy) {
 	strategy.Initialize()
 }
 
-func (SB *StrategyBuilder) LaunchAccount(strategy AccountStrategyInterface) error {
+func (SB *StrategyBuilder) LaunchAccount(ctx context.Context, strategy AccountStrategyInterface) error {
 	var err error
-	err = strategy.InitAccount()
+	err = strategy.InitAccount(ctx)
 	return err
 }
 
-func (SB *StrategyBuilder) LaunchOrderBook(strategy OrderBookStrategyInterface) error {
+func (SB *StrategyBuilder) LaunchOrderBook(ctx context.Context, strategy OrderBookStrategyInterface) error {
 	var err error
-	err = strategy.InitOrderBook()
+	err = strategy.InitOrderBook(ctx)
 	return err
 }
 
@@ -50,9 +64,15 @@ func (SB *StrategyBuilder) LaunchClusters(strategy ClusterStrategyInterface) err
 	return err
 }
 
-func (SB *StrategyBuilder) LaunchCandles(strategy CandlesStrategyInterface) error {
+func (SB *StrategyBuilder) LaunchCandles(ctx context.Context, strategy CandlesStrategyInterface) error {
+	var err error
+	err = strategy.InitCandles(ctx)
+	return err
+}
+
+func (SB *StrategyBuilder) LaunchMarkPrice(strategy MarkPriceStrategyInterface) error {
 	var err error
-	err = strategy.InitCandles()
+	err = strategy.InitMarkPrice()
 	return err
 }
 
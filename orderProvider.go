@@ -2,115 +2,556 @@ package binance_modules
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/adshao/go-binance/v2/futures"
 	"github.com/shopspring/decimal"
 )
 
+const (
+	defaultClientOrderIDPrefix = "bm"
+	clientOrderIDPrefixLimit   = 12
+	errCodeOrderNotFound       = -2013
+	errCodeCancelRejected      = -2011 // order to cancel is unknown, e.g. it is already filled or canceled
+	orderLookupDelay           = time.Second
+	orderLookupTimeout         = 15 * time.Second // of the lookup after the caller context is done
+)
+
 type OrderProvider struct {
-	client futures.Client
+	client    futures.Client
+	statuses  map[string]*Status // by symbol, shared by copies of the provider
+	account   *Account
+	validator *OrderValidator
+	risk      *RiskEngine
+	guard     *DataGuard
+	policy    RetryPolicy
+	prefix    string
+	session   int64   // ms, makes ids unique across restarts
+	sequence  *uint64 // shared by copies of the provider
 }
 
 func NewOrderProvider(client *futures.Client) OrderProvider {
-	return OrderProvider{client: *client}
+	return OrderProvider{
+		client:    *client,
+		statuses:  make(map[string]*Status),
+		validator: NewOrderValidator(),
+		policy:    DefaultRetryPolicy,
+		prefix:    defaultClientOrderIDPrefix,
+		session:   time.Now().UnixMilli(),
+		sequence:  new(uint64),
+	}
 }
 
-func (op *OrderProvider) SetLeverage(symbol futures.Symbol, leverage int) (*futures.SymbolLeverage, error) {
-	response, err := op.client.NewChangeLeverageService().Symbol(symbol.Symbol).Leverage(leverage).Do(context.Background())
+// SetClientOrderIDPrefix sets strategy name used as prefix of generated client order ids
+func (op *OrderProvider) SetClientOrderIDPrefix(prefix string) {
+	op.prefix = clientOrderIDPrefix(prefix)
+}
 
-	return response, err
+// clientOrderIDPrefix keeps characters allowed in client order ids and cuts the name to the prefix limit
+func clientOrderIDPrefix(name string) string {
+	var builder strings.Builder
+	for _, char := range name {
+		if builder.Len() == clientOrderIDPrefixLimit {
+			break
+		}
+		if (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') || char == '_' {
+			builder.WriteRune(char)
+		}
+	}
+	if builder.Len() == 0 {
+		return defaultClientOrderIDPrefix
+	}
+	return builder.String()
+}
+
+// NewClientOrderID returns prefix-session-sequence id, it fits 36 characters allowed by exchange
+func (op *OrderProvider) NewClientOrderID() string {
+	sequence := atomic.AddUint64(op.sequence, 1)
+	return op.prefix + "-" + strconv.FormatInt(op.session, 36) + "-" + strconv.FormatUint(sequence, 36)
+}
+
+// SetRetryPolicy sets timeouts of all requests and attempts of queries and order placement,
+// an order is sent again only after exchange confirms it does not know the previous attempt
+func (op *OrderProvider) SetRetryPolicy(policy RetryPolicy) {
+	op.policy = policy
+}
+
+func (op *OrderProvider) retryPolicy() RetryPolicy {
+	return op.policy.withTimeSync(&op.client)
+}
+
+// Track makes cancel, modify and query results applied to the status of its symbol before user data events arrive
+func (op *OrderProvider) Track(status *Status) {
+	op.statuses[status.symbol] = status
+}
+
+// TrackAccount tracks every symbol by statuses of the account which are not tracked by Track
+func (op *OrderProvider) TrackAccount(account *Account) {
+	op.account = account
+}
+
+// SetValidator replaces the default symbol filters validator, nil leaves only request fields checked
+func (op *OrderProvider) SetValidator(validator *OrderValidator) {
+	op.validator = validator
+}
+
+// SetRiskEngine makes every new order pass pre-trade risk checks after symbol filters
+func (op *OrderProvider) SetRiskEngine(risk *RiskEngine) {
+	op.risk = risk
+}
+
+// SetDataGuard blocks new orders while market data is stale, orders closing positions pass
+func (op *OrderProvider) SetDataGuard(guard *DataGuard) {
+	op.guard = guard
+}
+
+// validate checks symbol filters, data freshness and risk limits, pending is number of orders not open in status yet
+func (op *OrderProvider) validate(r *OrderRequest, pending int) error {
+	err := op.validateFilters(r, pending)
+	if err != nil {
+		return err
+	}
+	if op.guard != nil && op.guard.IsStale() && !op.closes(r) {
+		return ErrStaleData
+	}
+	if op.risk == nil {
+		return nil
+	}
+	return op.risk.Check(r, pending)
+}
+
+// reserve validates the request and registers it pending in the tracked status in one step, so requests
+// checked after it, concurrent ones and siblings of a batch, count it as an order of its direction.
+// unregistered is number of orders sent together with it which have no tracked status.
+func (op *OrderProvider) reserve(r *OrderRequest, unregistered int) error {
+	status := op.tracked(r.Symbol.Symbol)
+	if status == nil {
+		return op.validate(r, unregistered)
+	}
+	return status.Orders.Reserve(r, time.Now().UnixMilli(), func() error {
+		return op.validate(r, unregistered+len(status.Orders.Pending()))
+	})
+}
+
+// closes reports orders which can only decrease the position, net of other orders closing it
+func (op *OrderProvider) closes(r *OrderRequest) bool {
+	if status := op.tracked(r.Symbol.Symbol); status != nil {
+		return reducesPosition(r, status)
+	}
+	return r.IsClosePosition || r.IsReduceOnly
 }
 
-func (op *OrderProvider) MarketOrder(symbol futures.Symbol, side string, quantity float64) (*futures.CreateOrderResponse, error) {
+// validateFilters uses mark price and open orders of the tracked status, pending is number of orders
+// not open in status yet, modifications pass -1 as the modified order is one of the open ones
+func (op *OrderProvider) validateFilters(r *OrderRequest, pending int) error {
+	if op.validator == nil {
+		return r.Validate()
+	}
 	var (
-		fmt_symbol   string
-		fmt_quantity string
-		fmt_side     futures.SideType
-		fmt_type     futures.OrderType
+		markPrice  decimal.Decimal
+		openOrders = pending
 	)
-	fmt_symbol = symbol.Symbol
-	fmt_quantity = op.quantityToString(quantity, symbol.LotSizeFilter())
-	fmt_type = futures.OrderTypeMarket
+	if status := op.tracked(r.Symbol.Symbol); status != nil {
+		markPrice = status.MarkPrice()
+		openOrders += len(status.Orders.Open())
+	}
+	return op.validator.Validate(r, markPrice, openOrders)
+}
 
-	if side == "BUY" {
-		fmt_side = futures.SideTypeBuy
-	} else {
-		fmt_side = futures.SideTypeSell
+func (op *OrderProvider) tracked(symbol string) *Status {
+	if status, ok := op.statuses[symbol]; ok {
+		return status
 	}
+	if op.account != nil {
+		return op.account.Status(symbol)
+	}
+	return nil
+}
 
-	order, err := op.client.NewCreateOrderService().
-		Symbol(fmt_symbol).
-		Side(fmt_side).Type(fmt_type).
-		Quantity(fmt_quantity).
-		Do(context.Background())
+func (op *OrderProvider) SetLeverage(ctx context.Context, symbol futures.Symbol, leverage int) (*futures.SymbolLeverage, error) {
+	response, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (*futures.SymbolLeverage, error) {
+		return op.client.NewChangeLeverageService().Symbol(symbol.Symbol).Leverage(leverage).Do(ctx)
+	})
 
-	return order, err
+	return response, err
 }
 
-func (op *OrderProvider) LimitOrder(symbol futures.Symbol, side string, quantity, price float64) (*futures.CreateOrderResponse, error) {
+// Submit places the order under a client order id, after ambiguous failures the order is queried
+// by that id and sent again only if exchange confirms it does not know it, so retries never
+// duplicate orders. The order is looked up as well when ctx is done after it may have been sent.
+// When the outcome stays unknown the order is kept pending in the status.
+func (op *OrderProvider) Submit(ctx context.Context, request *OrderRequest) (*futures.CreateOrderResponse, error) {
+	if request.ClientOrderID == "" {
+		request.ClientOrderID = op.NewClientOrderID()
+	}
+	service, err := op.createOrderService(request)
+	if err != nil {
+		return nil, err
+	}
+	err = op.reserve(request, 0)
+	if err != nil {
+		return nil, err
+	}
+	status := op.tracked(request.Symbol.Symbol)
+
 	var (
-		fmt_symbol   string
-		fmt_quantity string
-		fmt_price    string
-		fmt_side     futures.SideType
-		fmt_type     futures.OrderType
-		fmt_time     futures.TimeInForceType
+		order   *futures.CreateOrderResponse
+		unknown bool // order may be placed
 	)
-	fmt_symbol = symbol.Symbol
-	fmt_quantity = op.quantityToString(quantity, symbol.LotSizeFilter())
-	fmt_price = op.priceToString(price, symbol.PriceFilter())
-	fmt_type = futures.OrderTypeLimit
-	fmt_type = futures.OrderType(futures.TimeInForceTypeGTC)
+	for i := 0; i < op.policy.Attempts || i == 0; i++ {
+		if i > 0 {
+			timer := time.NewTimer(op.policy.backoff(i - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
 
-	if side == "BUY" {
-		fmt_side = futures.SideTypeBuy
-	} else {
-		fmt_side = futures.SideTypeSell
-	}
+		order, err = once(ctx, op.policy, func(ctx context.Context) (*futures.CreateOrderResponse, error) {
+			return service.Do(ctx)
+		})
+		// the caller may give up after the request is sent, then the order may be placed too
+		canceled := err != nil && ctx.Err() != nil
+		if err == nil || (!canceled && !IsRetryable(err)) {
+			unknown = false
+			break
+		}
 
-	order, err := op.client.NewCreateOrderService().
-		Symbol(fmt_symbol).
-		Side(fmt_side).
-		Type(fmt_type).
-		Quantity(fmt_quantity).
-		Price(fmt_price).
-		TimeInForce(fmt_time).
-		Do(context.Background())
+		unknown = true
+		lookupCtx := ctx
+		if canceled {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithTimeout(context.Background(), orderLookupTimeout)
+			defer cancel()
+		} else {
+			op.retryPolicy().syncTime(ctx, err)
+		}
+		existing, queryErr := op.lookupClientOrder(lookupCtx, request)
+		if queryErr != nil {
+			break
+		}
+		if existing != nil {
+			order, err, unknown = CreateOrderResponseAdapter(existing), nil, false
+			break
+		}
+		unknown = false
+		if canceled {
+			break
+		}
+	}
 
+	if status != nil {
+		if err == nil {
+			status.CreateOrderUpdate(order)
+		} else if !unknown {
+			status.Orders.RemovePending(request.ClientOrderID)
+		}
+	}
 	return order, err
 }
 
-func (op *OrderProvider) priceToString(price float64, filter *futures.PriceFilter) string {
-	precision := strings.Index(filter.TickSize, "1")
-	extra_zeroes := len(filter.TickSize) - precision - 1
+// lookupClientOrder finds the order after an ambiguous submit, nil order means exchange does not
+// know it. Not found is confirmed after a delay because exchange may not show a just placed order yet.
+func (op *OrderProvider) lookupClientOrder(ctx context.Context, request *OrderRequest) (*futures.Order, error) {
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			timer := time.NewTimer(orderLookupDelay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		order, err := op.QueryClientOrder(ctx, request.Symbol, request.ClientOrderID)
+		if err == nil {
+			return order, nil
+		}
+		if !isOrderNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (op *OrderProvider) MarketOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeMarket).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) LimitOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, price decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeLimit).
+		WithPositionSide(positionSide).
+		WithTimeInForce(futures.TimeInForceTypeGTC).
+		WithQuantity(quantity).
+		WithPrice(price)
 
-	if precision > 0 {
-		precision -= 1
+	return op.Submit(ctx, request)
+}
+
+// MarketOrderFloat and LimitOrderFloat are shortcuts for float inputs
+func (op *OrderProvider) MarketOrderFloat(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity float64) (*futures.CreateOrderResponse, error) {
+	return op.MarketOrder(ctx, symbol, side, positionSide, decimal.NewFromFloat(quantity))
+}
+
+func (op *OrderProvider) LimitOrderFloat(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, price float64) (*futures.CreateOrderResponse, error) {
+	return op.LimitOrder(ctx, symbol, side, positionSide, decimal.NewFromFloat(quantity), decimal.NewFromFloat(price))
+}
+
+func (op *OrderProvider) StopOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, price, stopPrice decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeStop).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity).
+		WithPrice(price).
+		WithStopPrice(stopPrice)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) StopMarketOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, stopPrice decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeStopMarket).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity).
+		WithStopPrice(stopPrice)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) TakeProfitOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, price, stopPrice decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeTakeProfit).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity).
+		WithPrice(price).
+		WithStopPrice(stopPrice)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) TakeProfitMarketOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, stopPrice decimal.Decimal) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeTakeProfitMarket).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity).
+		WithStopPrice(stopPrice)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) TrailingStopMarketOrder(ctx context.Context, symbol futures.Symbol, side string, positionSide futures.PositionSideType, quantity, activationPrice decimal.Decimal, callbackRate float64) (*futures.CreateOrderResponse, error) {
+	request := NewOrderRequest(symbol, sideType(side), futures.OrderTypeTrailingStopMarket).
+		WithPositionSide(positionSide).
+		WithQuantity(quantity).
+		WithActivationPrice(activationPrice).
+		WithCallbackRate(callbackRate)
+
+	return op.Submit(ctx, request)
+}
+
+func (op *OrderProvider) CancelOrder(ctx context.Context, symbol futures.Symbol, orderID int64) (*futures.CancelOrderResponse, error) {
+	return op.cancelOrder(ctx, op.client.NewCancelOrderService().Symbol(symbol.Symbol).OrderID(orderID))
+}
+
+func (op *OrderProvider) CancelClientOrder(ctx context.Context, symbol futures.Symbol, clientOrderID string) (*futures.CancelOrderResponse, error) {
+	return op.cancelOrder(ctx, op.client.NewCancelOrderService().Symbol(symbol.Symbol).OrigClientOrderID(clientOrderID))
+}
+
+func (op *OrderProvider) cancelOrder(ctx context.Context, service *futures.CancelOrderService) (*futures.CancelOrderResponse, error) {
+	response, err := once(ctx, op.policy, func(ctx context.Context) (*futures.CancelOrderResponse, error) {
+		return service.Do(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := op.tracked(response.Symbol); status != nil {
+		status.CancelOrderUpdate(response)
+	}
+	return response, nil
+}
+
+func (op *OrderProvider) CancelAllOrders(ctx context.Context, symbol futures.Symbol) error {
+	_, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, op.client.NewCancelAllOpenOrdersService().Symbol(symbol.Symbol).Do(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if status := op.tracked(symbol.Symbol); status != nil {
+		status.CancelAllUpdate(time.Now().UnixMilli())
 	}
-	stringPrice := decimal.NewFromFloat(price).Round(int32(precision)).StringFixed(int32(precision) + int32(extra_zeroes))
+	return nil
+}
+
+// CountdownCancelAll cancels all open orders of the symbol if it is not called again within countdown, 0 disables it
+func (op *OrderProvider) CountdownCancelAll(ctx context.Context, symbol futures.Symbol, countdown time.Duration) error {
+	params := url.Values{}
+	params.Set("symbol", symbol.Symbol)
+	params.Set("countdownTime", strconv.FormatInt(countdown.Milliseconds(), 10))
 
-	return stringPrice
+	_, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) ([]byte, error) {
+		return signedRequest(ctx, &op.client, http.MethodPost, "/fapi/v1/countdownCancelAll", params)
+	})
+	return err
 }
 
-func (op *OrderProvider) quantityToString(quantity float64, filter *futures.LotSizeFilter) string {
-	precision := strings.Index(filter.StepSize, "1")
+// ModifyOrder changes price and quantity of an open limit order, side must match the order
+func (op *OrderProvider) ModifyOrder(ctx context.Context, symbol futures.Symbol, orderID int64, side futures.SideType, quantity, price decimal.Decimal) (*futures.Order, error) {
+	params := url.Values{}
+	params.Set("orderId", strconv.FormatInt(orderID, 10))
 
-	if precision > 0 {
-		precision -= 1
+	return op.modifyOrder(ctx, symbol, params, side, quantity, price)
+}
+
+func (op *OrderProvider) ModifyClientOrder(ctx context.Context, symbol futures.Symbol, clientOrderID string, side futures.SideType, quantity, price decimal.Decimal) (*futures.Order, error) {
+	params := url.Values{}
+	params.Set("origClientOrderId", clientOrderID)
+
+	return op.modifyOrder(ctx, symbol, params, side, quantity, price)
+}
+
+// modifiedOrder finds the order to modify in the tracked status
+func (op *OrderProvider) modifiedOrder(symbol futures.Symbol, params url.Values) *futures.Order {
+	status := op.tracked(symbol.Symbol)
+	if status == nil {
+		return nil
+	}
+	if clientOrderID := params.Get("origClientOrderId"); clientOrderID != "" {
+		return status.Orders.GetByClientID(clientOrderID)
 	}
+	orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+	return status.Orders.Get(orderID)
+}
 
-	stringQuantity := decimal.NewFromFloat(quantity).Round(int32(precision)).String()
+func (op *OrderProvider) modifyOrder(ctx context.Context, symbol futures.Symbol, params url.Values, side futures.SideType, quantity, price decimal.Decimal) (*futures.Order, error) {
+	request := NewOrderRequest(symbol, side, futures.OrderTypeLimit).
+		WithTimeInForce(futures.TimeInForceTypeGTC).
+		WithQuantity(quantity).
+		WithPrice(price)
+	if order := op.modifiedOrder(symbol, params); order != nil {
+		request.WithPositionSide(order.PositionSide).WithClientOrderID(order.ClientOrderID)
+		if order.TimeInForce != "" {
+			request.WithTimeInForce(order.TimeInForce)
+		}
+		if order.ReduceOnly && order.PositionSide == futures.PositionSideTypeBoth {
+			request.ReduceOnly()
+		}
+	}
+	// the modified order is open already, it is not a new one for the open orders limit
+	err := op.validateFilters(request, -1)
+	if err != nil {
+		return nil, err
+	}
+	if op.risk != nil {
+		err = op.risk.checkModify(request)
+		if err != nil {
+			return nil, err
+		}
+	}
+	params.Set("symbol", symbol.Symbol)
+	params.Set("side", string(side))
+	params.Set("quantity", op.quantityToString(request.Quantity, symbol.LotSizeFilter()))
+	params.Set("price", op.priceToString(request.Price, symbol.PriceFilter()))
+
+	data, err := once(ctx, op.policy, func(ctx context.Context) ([]byte, error) {
+		return signedRequest(ctx, &op.client, http.MethodPut, "/fapi/v1/order", params)
+	})
+	if err != nil {
+		return nil, err
+	}
+	order := new(futures.Order)
+	err = json.Unmarshal(data, order)
+	if err != nil {
+		return nil, err
+	}
+	if status := op.tracked(order.Symbol); status != nil {
+		status.ModifyOrderUpdate(order)
+	}
+	return order, nil
+}
+
+func (op *OrderProvider) QueryOrder(ctx context.Context, symbol futures.Symbol, orderID int64) (*futures.Order, error) {
+	return op.queryOrder(ctx, op.client.NewGetOrderService().Symbol(symbol.Symbol).OrderID(orderID))
+}
+
+func (op *OrderProvider) QueryClientOrder(ctx context.Context, symbol futures.Symbol, clientOrderID string) (*futures.Order, error) {
+	return op.queryOrder(ctx, op.client.NewGetOrderService().Symbol(symbol.Symbol).OrigClientOrderID(clientOrderID))
+}
+
+func (op *OrderProvider) queryOrder(ctx context.Context, service *futures.GetOrderService) (*futures.Order, error) {
+	order, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) (*futures.Order, error) {
+		return service.Do(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := op.tracked(order.Symbol); status != nil {
+		status.QueryOrderUpdate(order)
+	}
+	return order, nil
+}
 
-	return stringQuantity
+func (op *OrderProvider) OpenOrders(ctx context.Context, symbol futures.Symbol) ([]*futures.Order, error) {
+	orders, err := retry(ctx, op.retryPolicy(), func(ctx context.Context) ([]*futures.Order, error) {
+		return op.client.NewListOpenOrdersService().Symbol(symbol.Symbol).Do(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status := op.tracked(symbol.Symbol); status != nil {
+		for _, order := range orders {
+			status.QueryOrderUpdate(order)
+		}
+	}
+	return orders, nil
+}
+
+func isOrderNotFound(err error) bool {
+	return hasErrorCode(err, errCodeOrderNotFound)
+}
+
+// isOrderNotOpen reports cancel errors of orders which are already filled, canceled or unknown
+func isOrderNotOpen(err error) bool {
+	return isOrderNotFound(err) || hasErrorCode(err, errCodeCancelRejected)
+}
+
+func sideType(side string) futures.SideType {
+	if side == "BUY" {
+		return futures.SideTypeBuy
+	}
+	return futures.SideTypeSell
+}
+
+func (op *OrderProvider) priceToString(price decimal.Decimal, filter *futures.PriceFilter) string {
+	if filter == nil {
+		return price.String()
+	}
+	return formatStep(price, filter.TickSize, RoundNearest)
+}
+
+func (op *OrderProvider) quantityToString(quantity decimal.Decimal, filter *futures.LotSizeFilter) string {
+	if filter == nil {
+		return quantity.String()
+	}
+	return formatStep(quantity, filter.StepSize, RoundNearest)
 }
 
 func (op *OrderProvider) Testing(symbols []futures.Symbol, price float64) {
 
 	fmt.Printf("initial price: %v\n", price)
 	for _, symbol := range symbols {
-		strQ := op.priceToString(price, symbol.PriceFilter())
+		strQ := op.priceToString(decimal.NewFromFloat(price), symbol.PriceFilter())
 		fmt.Printf("string price: %v\ntick size: %v\n", strQ, symbol.PriceFilter().TickSize)
 	}
 }